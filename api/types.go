@@ -1,19 +1,31 @@
+// Package api holds the request and response types of the v1 API of the recommender, which are also used by the client.
+// The types are not versioned, changes to them have to stay backward compatible until a new API version is introduced.
 package api
 
 import (
+	"encoding/json"
+	"time"
+
 	gsc "github.com/elankath/gardener-scaling-common"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
 	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerv1 "k8s.io/kube-scheduler/config/v1"
 )
 
-// Create two funcs :- 1. Convert NodeGrpInfos to NodePools.
-// 2. Extract Recommendation from NodeGrpInfos
-// For each pair of scenario-CArecommendation, we run the simulation, get the recommendation
-// and print both. Also print which is better in terms of cost.
-// If priority expander is used by CA, then highlight that the comparison cannot be made
+// PodSource identifies from where the pods of a cluster snapshot are read.
+type PodSource string
+
+const (
+	// PodSourceTarget reads the pods from the target cluster identified by AppConfig.TargetKVCLKubeConfigPath.
+	PodSourceTarget PodSource = "target"
+	// PodSourceSnapshot reads the pods from the posted cluster snapshot, making the recommendation self-contained.
+	PodSourceSnapshot PodSource = "snapshot"
+)
 
 // AppConfig is the application configuration.
 type AppConfig struct {
@@ -22,6 +34,62 @@ type AppConfig struct {
 	BinaryAssetsPath         string
 	TargetKVCLKubeConfigPath string
 	ScoringStrategy          string
+	// PodSource is the default source of pods for recommendation requests.
+	PodSource PodSource
+	// JobRetention is the duration for which finished recommendation jobs are retained.
+	JobRetention time.Duration
+	// ListenAddress is the address on which the HTTP server listens.
+	ListenAddress string
+	// TLSCertFile and TLSKeyFile are the serving certificate and key. If set, the server serves HTTPS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile is the CA bundle used to verify client certificates. If set, clients must present a valid certificate
+	// for all requests except the health probes.
+	TLSClientCAFile string
+	// AuthTokenFile is a file containing the accepted bearer tokens, one per line.
+	AuthTokenFile string
+	// ArchiveDir is the directory in which the inputs and results of all recommender runs are archived.
+	ArchiveDir string
+	// ResponseCacheSize is the maximum number of cached recommendation responses. Zero disables the cache.
+	ResponseCacheSize int
+	// ResponseCacheTTL is the duration for which a recommendation response is cached.
+	ResponseCacheTTL time.Duration
+	// SimulationWorkers is the maximum number of candidates which are evaluated concurrently.
+	SimulationWorkers int
+	// SchedulerConfigPath is a KubeSchedulerConfiguration whose first profile is used to schedule pods of requests which
+	// do not pass their own profile. Only supported by the in-process simulator backend.
+	SchedulerConfigPath string
+	// SimulatorBackend is the backend used to simulate the scheduling of pods, either 'kvcl' or 'in-process'.
+	// BinaryAssetsPath is only required by the kvcl backend.
+	SimulatorBackend string
+	// AuthTokenReviewKubeConfigPath is the kubeconfig of the API server against which bearer tokens are verified using TokenReviews.
+	AuthTokenReviewKubeConfigPath string
+	// SystemOverhead are the resources reserved for system components on every node of requests which do not specify
+	// them. If nil, the overhead of cluster snapshots is derived from their kube-system pods.
+	SystemOverhead corev1.ResourceList
+	// NodeReservation are the resources reserved for the kubelet and the operating system on the nodes of node templates
+	// which are synthesised from the pricing catalog.
+	NodeReservation NodeReservation
+}
+
+// NodeReservation are the resources of a node which are not allocatable because they are reserved for the kubelet and the
+// operating system, keyed on the resource name. A value is either a quantity, e.g. 1Gi, or a percentage of the capacity,
+// e.g. 10%. CPU and memory which are not listed are reserved with the tiered kube-reserved formula of GKE.
+type NodeReservation map[corev1.ResourceName]string
+
+// ClusterSnapshot is a cluster snapshot as captured by gardener-scaling-common together with the storage objects of the
+// cluster, which the snapshot does not capture.
+type ClusterSnapshot struct {
+	gsc.ClusterSnapshot
+	Volumes
+}
+
+// Volumes are the storage objects referenced by the volumes of pods. All pods are simulated in the same namespace, hence
+// claims are moved to that namespace and are looked up by name only.
+type Volumes struct {
+	PersistentVolumes      []corev1.PersistentVolume      `json:"persistentVolumes,omitempty"`
+	PersistentVolumeClaims []corev1.PersistentVolumeClaim `json:"persistentVolumeClaims,omitempty"`
+	StorageClasses         []storagev1.StorageClass       `json:"storageClasses,omitempty"`
 }
 
 // NodePool represents a worker in gardener.
@@ -40,6 +108,9 @@ type PodInfo struct {
 	Spec              corev1.PodSpec    `json:"spec"`
 	NominatedNodeName string            `json:"nominatedNodeName,omitempty"`
 	Count             int               `json:"count"`
+	// OwnerReferences identify the controller of the pod. Pods owned by a DaemonSet are placed on every new node they
+	// tolerate instead of being scheduled.
+	OwnerReferences []metav1.OwnerReference `json:"ownerReferences,omitempty"`
 }
 
 // NodeInfo contains relevant information about a node.
@@ -78,6 +149,22 @@ type SimulationRequest struct {
 	// PodOrder is the order in which pods will be sorted and scheduled.
 	// If not provided, pods will be ordered in descending order of requested resources.
 	PodOrder *string `json:"podOrder,omitempty"`
+	// Seed makes the recommendation reproducible: runs of the same request with the same seed produce the same
+	// recommendation including the names of the recommended nodes. If not provided, a random seed is chosen.
+	Seed *int64 `json:"seed,omitempty"`
+	// SchedulerProfile is the profile with which the pods are scheduled, e.g. with the scoring plugin weights of the
+	// scheduler of the real cluster. Plugins which are not configured are defaulted like the kube-scheduler does. If not
+	// provided, the profile configured at startup or else the bin-packing profile of kvcl is used.
+	SchedulerProfile *schedulerv1.KubeSchedulerProfile `json:"schedulerProfile,omitempty"`
+	// SystemOverhead are the resources reserved for system components on every node, e.g. for the pods in kube-system
+	// which are not part of Pods. They are subtracted from the allocatable of the node templates.
+	SystemOverhead corev1.ResourceList `json:"systemOverhead,omitempty"`
+	// SyntheticNodeTemplates are the names of the node templates which were synthesised from the pricing catalog because
+	// the request had no template for their node pool and zone. It is set by the recommender.
+	SyntheticNodeTemplates []string `json:"syntheticNodeTemplates,omitempty"`
+	// Volumes constrain the nodes on which pods with persistent volume claims can be scheduled. The attach limits of CSI
+	// drivers are taken from the attachable-volumes-csi-<driver> resources in the allocatable of the node templates.
+	Volumes
 }
 
 type Recommendation struct {
@@ -91,6 +178,9 @@ type ScaleUpRecommendation struct {
 	IncrementBy  int32    `json:"incrementBy"`
 	InstanceType string   `json:"instanceType"`
 	NodeNames    []string `json:"nodeNames,omitempty"`
+	// SyntheticTemplate is true if the nodes were simulated with a node template synthesised from the pricing catalog, as
+	// the request had no template for the node pool and zone.
+	SyntheticTemplate bool `json:"syntheticTemplate,omitempty"`
 }
 
 type RecommendationResponse struct {
@@ -98,6 +188,154 @@ type RecommendationResponse struct {
 	UnscheduledPods []client.ObjectKey `json:"unscheduledPods"`
 	RunTime         string             `json:"runTime"`
 	Error           string             `json:"error,omitempty"`
+	// Partial is true if the recommender stopped early, e.g. because the deadline of the request expired. Recommendation
+	// then contains the scale-ups computed until then and PartialReason explains why the run stopped.
+	Partial       bool   `json:"partial,omitempty"`
+	PartialReason string `json:"partialReason,omitempty"`
+	// Seed is the seed the recommender ran with, passing it with the request reproduces the recommendation.
+	Seed *int64 `json:"seed,omitempty"`
+	// Explanation lists the scores of all candidates evaluated per round. It is only set if the request asked for it with explain=true.
+	Explanation []RunResultScores `json:"explanation,omitempty"`
+	// ValidationErrors lists all problems found in a request which was rejected as invalid.
+	ValidationErrors []ValidationError `json:"validationErrors,omitempty"`
+}
+
+// DefaultMaxNodesPerDesignedPool is the maximum of designed worker pools if the DesignRequest does not set one.
+const DefaultMaxNodesPerDesignedPool = 100
+
+// DesignRequest asks for new worker pools, chosen among the instance types of the pricing catalog, which schedule the
+// pending pods of the simulation request. The node pools of the simulation request are only used for comparison.
+type DesignRequest struct {
+	SimulationRequest
+	// Zones are the zones in which the new worker pools may be placed. If empty, the zones of the node pools are used.
+	Zones []string `json:"zones,omitempty"`
+	// InstanceFamilies restricts the candidates to the instance types of these families, e.g. m5 or n2. If empty, all
+	// families of the catalog are considered.
+	InstanceFamilies []string `json:"instanceFamilies,omitempty"`
+	// Architectures restricts the candidates to the instance types of these CPU architectures, amd64 or arm64. If empty,
+	// all architectures are considered.
+	Architectures []string `json:"architectures,omitempty"`
+	// MaxNodesPerPool is the maximum number of nodes of every designed worker pool. If zero,
+	// DefaultMaxNodesPerDesignedPool is used.
+	MaxNodesPerPool int32 `json:"maxNodesPerPool,omitempty"`
+}
+
+// DesignedWorkerPool is a new worker pool proposed by the designer.
+type DesignedWorkerPool struct {
+	Name         string   `json:"name"`
+	InstanceType string   `json:"instanceType"`
+	Architecture string   `json:"architecture"`
+	Zones        []string `json:"zones"`
+	// NodesPerZone is the number of nodes of the pool, per zone, which schedule the pending pods.
+	NodesPerZone map[string]int32 `json:"nodesPerZone"`
+	// Cost is the price of all nodes of the pool as listed in the pricing catalog.
+	Cost float64 `json:"cost"`
+}
+
+// DesignComparison is the scale-up of the existing node pools for the same pending pods.
+type DesignComparison struct {
+	Recommendation  Recommendation     `json:"recommendation"`
+	UnscheduledPods []client.ObjectKey `json:"unscheduledPods"`
+	// Cost is the price of all recommended nodes as listed in the pricing catalog.
+	Cost float64 `json:"cost"`
+}
+
+// DesignResponse lists the cheapest set of new worker pools which schedules the pending pods, alongside the scale-up of
+// the existing node pools. Costs are the 3-year reserved prices of the pricing catalog, which the cost-only scorer minimises.
+type DesignResponse struct {
+	WorkerPools     []DesignedWorkerPool `json:"workerPools"`
+	UnscheduledPods []client.ObjectKey   `json:"unscheduledPods"`
+	// Cost is the price of all nodes of the designed worker pools.
+	Cost float64 `json:"cost"`
+	// ExistingPools is the scale-up of the node pools of the request.
+	ExistingPools DesignComparison `json:"existingPools"`
+	// Savings is the cost of ExistingPools minus Cost. It is only comparable if both schedule the same pods.
+	Savings float64 `json:"savings"`
+	RunTime string  `json:"runTime"`
+	// Partial is true if either recommendation stopped early, PartialReason then explains why.
+	Partial       bool   `json:"partial,omitempty"`
+	PartialReason string `json:"partialReason,omitempty"`
+	// Seed is the seed both recommendations ran with.
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// ValidationError describes a single invalid value of a request.
+type ValidationError struct {
+	// Field is the path of the invalid value, e.g. workerPools[0].zones[1].
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NodeUtilisationInfo describes the pods placed on a node by the recommender and the resources they consume.
+type NodeUtilisationInfo struct {
+	Zone              string              `json:"zone,omitempty"`
+	NodePoolName      string              `json:"node_pool_name,omitempty"`
+	Pods              []string            `json:"pods"`
+	ResourcesConsumed corev1.ResourceList `json:"resources_consumed"`
+	Capacity          corev1.ResourceList `json:"capacity,omitempty"`
+}
+
+// JobStatus is the status of an asynchronous recommendation job.
+type JobStatus string
+
+const (
+	// JobPending indicates that the job has been accepted but is waiting for the virtual cluster to become free.
+	JobPending JobStatus = "Pending"
+	// JobRunning indicates that the recommender is running for the job.
+	JobRunning JobStatus = "Running"
+	// JobSucceeded indicates that the job finished and its result is available.
+	JobSucceeded JobStatus = "Succeeded"
+	// JobFailed indicates that the job finished with an error.
+	JobFailed JobStatus = "Failed"
+	// JobCancelled indicates that the job was cancelled before it could finish.
+	JobCancelled JobStatus = "Cancelled"
+)
+
+// RecommendationJob captures the status and the result of an asynchronous recommendation request.
+type RecommendationJob struct {
+	ID         string                  `json:"id"`
+	SnapshotID string                  `json:"snapshotID,omitempty"`
+	Status     JobStatus               `json:"status"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	StartedAt  *time.Time              `json:"startedAt,omitempty"`
+	FinishedAt *time.Time              `json:"finishedAt,omitempty"`
+	Result     *RecommendationResponse `json:"result,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+// IsFinished returns true if the job has reached a terminal status.
+func (j RecommendationJob) IsFinished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// ProgressEventType identifies the kind of progress event emitted while a recommendation is computed.
+type ProgressEventType string
+
+const (
+	// RoundStartedEvent is emitted at the start of every recommender round.
+	RoundStartedEvent ProgressEventType = "RoundStarted"
+	// CandidateScoredEvent is emitted once a node pool and zone combination has been simulated and scored.
+	CandidateScoredEvent ProgressEventType = "CandidateScored"
+	// WinnerChosenEvent is emitted when the winning node pool and zone of a round has been chosen.
+	WinnerChosenEvent ProgressEventType = "WinnerChosen"
+	// CompletedEvent is the last event of a successful run and carries the final RecommendationResponse.
+	CompletedEvent ProgressEventType = "Completed"
+	// ErrorEvent is the last event of a failed run.
+	ErrorEvent ProgressEventType = "Error"
+)
+
+// ProgressEvent describes the progress made by the recommender.
+type ProgressEvent struct {
+	Type          ProgressEventType       `json:"type"`
+	Time          time.Time               `json:"time"`
+	RunNumber     int                     `json:"runNumber,omitempty"`
+	NodePoolName  string                  `json:"nodePoolName,omitempty"`
+	Zone          string                  `json:"zone,omitempty"`
+	InstanceType  string                  `json:"instanceType,omitempty"`
+	Score         float64                 `json:"score,omitempty"`
+	PodsRemaining int                     `json:"podsRemaining"`
+	Response      *RecommendationResponse `json:"response,omitempty"`
+	Error         string                  `json:"error,omitempty"`
 }
 
 // types for logging scores
@@ -114,4 +352,50 @@ type NodePoolInstanceScore struct {
 	Winner         bool
 	Score          float64
 	NodeToPodNames map[string][]string
+	// Duration is the time taken to evaluate the candidate, e.g. 1.5s.
+	Duration string
+	// DaemonSetOverhead are the requests of the DaemonSet pods placed on the node of the candidate, which are not
+	// available to the pending pods.
+	DaemonSetOverhead corev1.ResourceList `json:",omitempty"`
+}
+
+// RunStatus is the outcome of an archived run.
+type RunStatus string
+
+const (
+	// RunInProgress indicates that the recommender is still running.
+	RunInProgress RunStatus = "InProgress"
+	// RunSucceeded indicates that a recommendation was computed.
+	RunSucceeded RunStatus = "Succeeded"
+	// RunFailed indicates that the recommender failed.
+	RunFailed RunStatus = "Failed"
+)
+
+// RunSummary describes a run of the recommender in the run archive.
+type RunSummary struct {
+	ID         string     `json:"id"`
+	RequestID  string     `json:"requestID,omitempty"`
+	Status     RunStatus  `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Partial    bool       `json:"partial,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ArchivedRun is an archived run with all its artifacts. Artifacts which were not written are omitted.
+type ArchivedRun struct {
+	RunSummary
+	// Input is the cluster snapshot or the simulation request for which the recommender ran.
+	Input json.RawMessage `json:"input,omitempty"`
+	// Scores are the scores of all candidates per round.
+	Scores json.RawMessage `json:"scores,omitempty"`
+	// NodeUtilisation describes how the recommended nodes are utilised.
+	NodeUtilisation json.RawMessage `json:"nodeUtilisation,omitempty"`
+	Response        json.RawMessage `json:"response,omitempty"`
+}
+
+// HealthStatus is the response body of the health endpoints. Checks maps the name of every check to ok or its error.
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
 }
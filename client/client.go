@@ -0,0 +1,290 @@
+// Package client is a typed Go client of the scaling recommender API.
+//
+//	c, err := client.New("https://localhost:8080", client.WithBearerToken(token))
+//	...
+//	response, err := c.Simulate(ctx, simRequest, client.RunOptions{Strategy: "cost-only", Timeout: 90 * time.Second})
+//
+// Failed requests are retried with exponential backoff as long as this is safe. GET requests are retried on any
+// connection error, including timeouts, and on 5xx statuses. All other requests are only retried if the connection to
+// the recommender could not be established, as nothing of the request has been sent then. A request which times out or
+// fails with a 5xx status may have been served already and is not retried. Recommendations for the pods of the target
+// cluster (PodSource api.PodSourceTarget, which may also be the default of the recommender) are never retried, only those
+// with PodSource api.PodSourceSnapshot are. The error envelope of a rejected request is returned as an *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"unmarshall/scaling-recommender/api"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a failed request is retried unless configured with WithRetries.
+	DefaultMaxRetries = 3
+	// DefaultInitialBackoff is the wait before the first retry, it doubles with every further retry.
+	DefaultInitialBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff caps the wait between retries.
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// Client calls the v1 API of a scaling recommender. It is safe for concurrent use.
+type Client struct {
+	baseURL        *url.URL
+	httpClient     *http.Client
+	tlsConfig      *tls.Config
+	timeout        time.Duration
+	token          string
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the Client send its requests with httpClient. It cannot be combined with WithTLSConfig or
+// WithTimeout, configure the HTTP client instead.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the recommender, e.g. to trust its CA or to present a
+// client certificate.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithTimeout limits the duration of every attempt of a request. Without it, requests are only limited by their context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithBearerToken sends token as bearer token with every request.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries configures how often a failed request is retried, if it is safe to retry it, and the backoff between the
+// attempts. A maxRetries of zero disables retries.
+func WithRetries(maxRetries int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a Client of the recommender at serverURL, e.g. https://localhost:8080.
+func New(serverURL string, opts ...Option) (*Client, error) {
+	baseURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL %q: %w", serverURL, err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL %q, expected an http or https URL", serverURL)
+	}
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/")
+	c := &Client{
+		baseURL:        baseURL,
+		maxRetries:     DefaultMaxRetries,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxRetries < 0 || c.initialBackoff < 0 || c.maxBackoff < c.initialBackoff {
+		return nil, fmt.Errorf("invalid retries %d with backoff between %s and %s", c.maxRetries, c.initialBackoff, c.maxBackoff)
+	}
+	if c.httpClient != nil {
+		if c.tlsConfig != nil || c.timeout != 0 {
+			return nil, errors.New("a custom HTTP client cannot be combined with a TLS config or a timeout")
+		}
+		return c, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.tlsConfig
+	c.httpClient = &http.Client{Transport: transport, Timeout: c.timeout}
+	return c, nil
+}
+
+// Error is returned if the recommender rejected a request or failed to serve it.
+type Error struct {
+	StatusCode int
+	// Message is the error of the response envelope, or the status text if the response carried none.
+	Message string
+	// ValidationErrors lists all problems found in a request which was rejected as invalid.
+	ValidationErrors []api.ValidationError
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "recommender responded with status %d: %s", e.StatusCode, e.Message)
+	for _, validationErr := range e.ValidationErrors {
+		_, _ = fmt.Fprintf(&sb, "; %s: %s", validationErr.Field, validationErr.Message)
+	}
+	return sb.String()
+}
+
+// IsStatus checks if err is an *Error with the given status code.
+func IsStatus(err error, statusCode int) bool {
+	var clientErr *Error
+	return errors.As(err, &clientErr) && clientErr.StatusCode == statusCode
+}
+
+// request describes a call of an endpoint.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	header http.Header
+	// acceptedStatusCodes are the status codes whose response body is decoded into the result, http.StatusOK if empty.
+	acceptedStatusCodes []int
+	// noRetry disables all retries of the request.
+	noRetry bool
+}
+
+// do sends the request, retrying it as long as canRetry allows, and returns the response of the last attempt. The caller
+// has to close the body of the response, whose status is one of the accepted status codes.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("cannot encode request: %w", err)
+		}
+	}
+	reqURL := c.baseURL.JoinPath(req.path)
+	reqURL.RawQuery = req.query.Encode()
+	backoff := c.initialBackoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, req, reqURL.String(), body)
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		if attempt == c.maxRetries || req.noRetry || !canRetry(req.method, response, err) {
+			if err != nil {
+				return nil, err
+			}
+			return c.checkStatus(response, req.acceptedStatusCodes)
+		}
+		if response != nil {
+			drainAndClose(response)
+		}
+		// full jitter spreads the retries of concurrent clients.
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, c.maxBackoff)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, reqURL string, body []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, req.method, reqURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	for name, values := range req.header {
+		httpRequest.Header[name] = values
+	}
+	if c.token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(httpRequest)
+}
+
+// checkStatus returns the response if its status is accepted, otherwise it closes the response and returns its error
+// envelope as an *Error.
+func (c *Client) checkStatus(response *http.Response, acceptedStatusCodes []int) (*http.Response, error) {
+	if len(acceptedStatusCodes) == 0 {
+		acceptedStatusCodes = []int{http.StatusOK}
+	}
+	for _, statusCode := range acceptedStatusCodes {
+		if response.StatusCode == statusCode {
+			return response, nil
+		}
+	}
+	defer drainAndClose(response)
+	clientErr := &Error{StatusCode: response.StatusCode}
+	var envelope api.RecommendationResponse
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err == nil {
+		clientErr.Message = envelope.Error
+		clientErr.ValidationErrors = envelope.ValidationErrors
+	}
+	if clientErr.Message == "" {
+		clientErr.Message = http.StatusText(response.StatusCode)
+	}
+	return nil, clientErr
+}
+
+// call sends the request and decodes the body of the response into result.
+func (c *Client) call(ctx context.Context, req request, result any) error {
+	response, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(response)
+	if err = json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("cannot decode response of %s %s: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// canRetry checks if a request with the given method, which failed with err or responded with response, can be sent
+// again. GET requests do not change the state of the recommender and are retried on any error and on 5xx statuses. Other
+// requests may have been served even if they failed, e.g. with a timeout, and are only retried if the connection could
+// not be established.
+func canRetry(method string, response *http.Response, err error) bool {
+	if err != nil {
+		return method == http.MethodGet || isDialError(err)
+	}
+	return method == http.MethodGet && isRetryableStatus(response.StatusCode)
+}
+
+// isDialError checks if err occurred while connecting to the recommender, in which case nothing of the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError && statusCode != http.StatusNotImplemented
+}
+
+// drainAndClose reads the rest of the body so that the connection can be reused.
+func drainAndClose(response *http.Response) {
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+}
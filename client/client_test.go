@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"unmarshall/scaling-recommender/api"
+)
+
+// newTestClient returns a client of server which retries failed requests up to three times without noticeable backoff.
+func newTestClient(t *testing.T, serverURL string, opts ...Option) *Client {
+	t.Helper()
+	c, err := New(serverURL, append([]Option{WithRetries(3, time.Millisecond, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestGetIsRetriedOn5xx(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, api.RecommendationResponse{Error: "busy"})
+			return
+		}
+		writeJSON(w, http.StatusOK, api.RecommendationJob{ID: "job-1", Status: api.JobSucceeded})
+	}))
+	defer server.Close()
+
+	job, err := newTestClient(t, server.URL).GetRecommendation(context.Background(), "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "job-1" || attempts.Load() != 3 {
+		t.Errorf("got job %q after %d attempts, want job-1 after 3 attempts", job.ID, attempts.Load())
+	}
+}
+
+func TestPostIsNotRetriedAfterTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// the request may have been served, the client gives up before the response is written.
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := newTestClient(t, server.URL, WithTimeout(50*time.Millisecond)).Simulate(context.Background(), &api.SimulationRequest{}, RunOptions{})
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("got %d attempts, want 1", attempts.Load())
+	}
+}
+
+// dialFailure fails every request as if the recommender could not be reached and counts the attempts.
+type dialFailure struct {
+	attempts atomic.Int32
+}
+
+func (d *dialFailure) RoundTrip(*http.Request) (*http.Response, error) {
+	d.attempts.Add(1)
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+}
+
+func TestRecommendIsOnlyRetriedForSnapshotPods(t *testing.T) {
+	tests := []struct {
+		podSource    api.PodSource
+		wantAttempts int32
+	}{
+		{podSource: "", wantAttempts: 1},
+		{podSource: api.PodSourceTarget, wantAttempts: 1},
+		{podSource: api.PodSourceSnapshot, wantAttempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run("podSource="+string(tt.podSource), func(t *testing.T) {
+			transport := &dialFailure{}
+			c := newTestClient(t, "http://recommender.invalid", WithHTTPClient(&http.Client{Transport: transport}))
+			if _, err := c.Recommend(context.Background(), &api.ClusterSnapshot{}, RunOptions{PodSource: tt.podSource}); err == nil {
+				t.Fatal("expected the request to fail")
+			}
+			if transport.attempts.Load() != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", transport.attempts.Load(), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsAreDecoded(t *testing.T) {
+	validationErrors := []api.ValidationError{
+		{Field: "nodePools[0].zones", Message: "at least one zone is required"},
+		{Field: "pods", Message: "at least one pod is required"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnprocessableEntity, api.RecommendationResponse{Error: "invalid simulation request", ValidationErrors: validationErrors})
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(t, server.URL).Simulate(context.Background(), &api.SimulationRequest{}, RunOptions{})
+	var clientErr *Error
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if clientErr.StatusCode != http.StatusUnprocessableEntity || clientErr.Message != "invalid simulation request" {
+		t.Errorf("got status %d with message %q, want status 422 with message %q", clientErr.StatusCode, clientErr.Message, "invalid simulation request")
+	}
+	if !reflect.DeepEqual(clientErr.ValidationErrors, validationErrors) {
+		t.Errorf("got validation errors %v, want %v", clientErr.ValidationErrors, validationErrors)
+	}
+}
+
+func TestIDsAreEscaped(t *testing.T) {
+	var requestURIs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURIs = append(requestURIs, r.RequestURI)
+		if r.Method == http.MethodDelete {
+			writeJSON(w, http.StatusAccepted, api.RecommendationJob{})
+			return
+		}
+		writeJSON(w, http.StatusOK, api.RecommendationJob{})
+	}))
+	defer server.Close()
+
+	c := newTestClient(t, server.URL)
+	id := "a/b?c"
+	if _, err := c.GetRecommendation(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CancelRecommendation(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetRun(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/v1/recommendations/a%2Fb%3Fc", "/v1/recommendations/a%2Fb%3Fc", "/v1/runs/a%2Fb%3Fc"}
+	if !reflect.DeepEqual(requestURIs, want) {
+		t.Errorf("got request URIs %v, want %v", requestURIs, want)
+	}
+}
+
+func TestSubmitRecommendationSendsNoCache(t *testing.T) {
+	var cacheControl string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheControl = r.Header.Get("Cache-Control")
+		writeJSON(w, http.StatusAccepted, api.RecommendationJob{ID: "job-1"})
+	}))
+	defer server.Close()
+
+	if _, err := newTestClient(t, server.URL).SubmitRecommendation(context.Background(), &api.ClusterSnapshot{}, RunOptions{NoCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if cacheControl != "no-cache" {
+		t.Errorf("got Cache-Control %q, want no-cache", cacheControl)
+	}
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"unmarshall/scaling-recommender/api"
+)
+
+// ndjsonContentType is the media type with which progress events are streamed as newline delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+// RunOptions are the query parameters and headers of the recommendation endpoints. Zero values are not sent, so the
+// defaults of the recommender apply.
+type RunOptions struct {
+	// PodSource is the source of the pods of a cluster snapshot, it is ignored by the other endpoints.
+	PodSource api.PodSource
+	// Timeout is the deadline of the recommendation, once it expires the recommendations computed so far are returned
+	// as a partial response.
+	Timeout time.Duration
+	// Seed reproduces the recommendation of an earlier response.
+	Seed *int64
+	// Explain adds the scores of all candidates per round to the response.
+	Explain bool
+	// Strategy overrides the scoring strategy configured at startup of the recommender.
+	Strategy string
+	// NoCache recomputes the recommendation instead of serving a cached response.
+	NoCache bool
+}
+
+func (o RunOptions) query() url.Values {
+	query := url.Values{}
+	if o.PodSource != "" {
+		query.Set("podSource", string(o.PodSource))
+	}
+	if o.Timeout > 0 {
+		query.Set("timeout", o.Timeout.String())
+	}
+	if o.Seed != nil {
+		query.Set("seed", strconv.FormatInt(*o.Seed, 10))
+	}
+	if o.Explain {
+		query.Set("explain", "true")
+	}
+	if o.Strategy != "" {
+		query.Set("strategy", o.Strategy)
+	}
+	return query
+}
+
+// targetsCluster checks if the recommendation may be computed for the pods of the target cluster. The default pod source
+// is configured at startup of the recommender, so only an explicit snapshot source rules this out.
+func (o RunOptions) targetsCluster() bool {
+	return o.PodSource != api.PodSourceSnapshot
+}
+
+func (o RunOptions) header() http.Header {
+	header := http.Header{}
+	if o.NoCache {
+		header.Set("Cache-Control", "no-cache")
+	}
+	return header
+}
+
+// Recommend computes a recommendation for the cluster snapshot with POST /v1/recommend. Recommendations for the pods of
+// the target cluster are applied to it by the recommender, hence the request is not retried unless opts.PodSource is
+// api.PodSourceSnapshot.
+func (c *Client) Recommend(ctx context.Context, cs *api.ClusterSnapshot, opts RunOptions) (*api.RecommendationResponse, error) {
+	response := &api.RecommendationResponse{}
+	req := request{method: http.MethodPost, path: "/v1/recommend", query: opts.query(), header: opts.header(), body: cs, noRetry: opts.targetsCluster()}
+	if err := c.call(ctx, req, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// RecommendStream computes a recommendation for the cluster snapshot like Recommend, but streams the progress of the
+// recommender. onEvent is called for every progress event before the final one, the response of the final event is
+// returned. A run which failed after the stream started is returned as an *Error with status 500.
+func (c *Client) RecommendStream(ctx context.Context, cs *api.ClusterSnapshot, opts RunOptions, onEvent func(api.ProgressEvent)) (*api.RecommendationResponse, error) {
+	header := opts.header()
+	header.Set("Accept", ndjsonContentType)
+	response, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/recommend", query: opts.query(), header: header, body: cs, noRetry: opts.targetsCluster()})
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(response)
+	scanner := bufio.NewScanner(response.Body)
+	// a completed event carries the whole response.
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event api.ProgressEvent
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("cannot decode progress event: %w", err)
+		}
+		switch event.Type {
+		case api.CompletedEvent:
+			if event.Response == nil {
+				return nil, errors.New("completed event carries no response")
+			}
+			return event.Response, nil
+		case api.ErrorEvent:
+			return nil, &Error{StatusCode: http.StatusInternalServerError, Message: event.Error}
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("stream ended without a completed event")
+}
+
+// Simulate computes a recommendation for the simulation request with POST /v1/simulate.
+func (c *Client) Simulate(ctx context.Context, simRequest *api.SimulationRequest, opts RunOptions) (*api.RecommendationResponse, error) {
+	opts.PodSource = ""
+	response := &api.RecommendationResponse{}
+	req := request{method: http.MethodPost, path: "/v1/simulate", query: opts.query(), header: opts.header(), body: simRequest}
+	if err := c.call(ctx, req, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Design proposes new worker pools for the pending pods of the design request with POST /v1/design.
+func (c *Client) Design(ctx context.Context, designRequest *api.DesignRequest, opts RunOptions) (*api.DesignResponse, error) {
+	opts.PodSource = ""
+	response := &api.DesignResponse{}
+	req := request{method: http.MethodPost, path: "/v1/design", query: opts.query(), header: opts.header(), body: designRequest}
+	if err := c.call(ctx, req, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// SubmitRecommendation submits an asynchronous recommendation job for the cluster snapshot with POST
+// /v1/recommendations.
+func (c *Client) SubmitRecommendation(ctx context.Context, cs *api.ClusterSnapshot, opts RunOptions) (*api.RecommendationJob, error) {
+	job := &api.RecommendationJob{}
+	req := request{method: http.MethodPost, path: "/v1/recommendations", query: opts.query(), header: opts.header(), body: cs, acceptedStatusCodes: []int{http.StatusAccepted}}
+	if err := c.call(ctx, req, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetRecommendation returns the recommendation job with the given ID.
+func (c *Client) GetRecommendation(ctx context.Context, id string) (*api.RecommendationJob, error) {
+	job := &api.RecommendationJob{}
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/v1/recommendations/" + url.PathEscape(id)}, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// CancelRecommendation cancels the recommendation job with the given ID. It fails with status 409 if the job has
+// already finished.
+func (c *Client) CancelRecommendation(ctx context.Context, id string) (*api.RecommendationJob, error) {
+	job := &api.RecommendationJob{}
+	req := request{method: http.MethodDelete, path: "/v1/recommendations/" + url.PathEscape(id), acceptedStatusCodes: []int{http.StatusAccepted}}
+	if err := c.call(ctx, req, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// WaitForRecommendation polls the recommendation job with the given ID every pollInterval until it has finished.
+func (c *Client) WaitForRecommendation(ctx context.Context, id string, pollInterval time.Duration) (*api.RecommendationJob, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetRecommendation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.IsFinished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ListRuns returns the archived runs of the recommender, the most recent run first.
+func (c *Client) ListRuns(ctx context.Context) ([]api.RunSummary, error) {
+	var runs []api.RunSummary
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/v1/runs"}, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetRun returns the archived run with the given ID and all its artifacts.
+func (c *Client) GetRun(ctx context.Context, id string) (*api.ArchivedRun, error) {
+	run := &api.ArchivedRun{}
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/v1/runs/" + url.PathEscape(id)}, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// OpenAPIDocument returns the OpenAPI document of the v1 API.
+func (c *Client) OpenAPIDocument(ctx context.Context) (json.RawMessage, error) {
+	var document json.RawMessage
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/v1/openapi.json"}, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Healthz returns the liveness status of the recommender. A recommender whose checks failed responds with status 503,
+// its status is returned without error so that the failed checks can be inspected.
+func (c *Client) Healthz(ctx context.Context) (*api.HealthStatus, error) {
+	return c.healthStatus(ctx, "/healthz")
+}
+
+// Readyz returns the readiness status of the recommender like Healthz.
+func (c *Client) Readyz(ctx context.Context) (*api.HealthStatus, error) {
+	return c.healthStatus(ctx, "/readyz")
+}
+
+func (c *Client) healthStatus(ctx context.Context, path string) (*api.HealthStatus, error) {
+	status := &api.HealthStatus{}
+	// an unhealthy recommender is not retried, its status is the answer.
+	noRetries := *c
+	noRetries.maxRetries = 0
+	req := request{method: http.MethodGet, path: path, acceptedStatusCodes: []int{http.StatusOK, http.StatusServiceUnavailable}}
+	if err := noRetries.call(ctx, req, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
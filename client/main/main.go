@@ -1,51 +1,80 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/json"
+	"crypto/x509"
+	"flag"
 	"fmt"
-	gsc "github.com/elankath/gardener-scaling-common"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	scalehist "github.com/elankath/gardener-scaling-history"
+
 	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/client"
 	"unmarshall/scaling-recommender/client/util"
+	"unmarshall/scaling-recommender/internal/pricing"
+	"unmarshall/scaling-recommender/scenario"
 )
 
+// authTokenEnv is the environment variable from which the auth token is taken if the token flag is not set, which keeps
+// the token out of the process list.
+const authTokenEnv = "SCALING_RECOMMENDER_TOKEN"
+
+// defaultRequestTimeout is the timeout of requests to the recommender if no timeout is configured.
+const defaultRequestTimeout = 10 * time.Minute
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"recommend", "recommend a scale-up for the cluster snapshot of a scaling-history scenario", runRecommend},
+	{"simulate", "recommend a scale-up for a scenario file", runSimulate},
+	{"compare", "compare the recommendation for a scaling-history scenario with the scale-up of cluster-autoscaler", runCompare},
+	{"batch", "run a batch of scenarios and write CSV and Markdown reports", runBatch},
+	{"pricing", "list the pricing catalog of a provider", runPricing},
+}
+
+// serverFlags are the flags of the commands which call the recommender.
+type serverFlags struct {
+	url                   string
+	token                 string
+	caFile                string
+	insecureSkipTLSVerify bool
+	strategy              string
+	timeout               time.Duration
+	output                string
+}
+
 func main() {
-	//simRequest, err := util.CreateSimRequest(filepath.Join("client", "assets", "s1.json"))
-	//simRequest, err := util.CreateSimRequest(filepath.Join("client", "assets", "s2.json"))
-	//simRequest, err := util.CreateSimRequest(filepath.Join("client", "assets", "s3.json"))
-	//simRequest, err := util.CreateSimRequest(filepath.Join("client", "assets", "s4.json"))
-	//simRequest, err := util.CreateSimRequest(filepath.Join("client", "assets", "s5.json"))
-	//if err != nil {
-	//	slog.Error("Error in creating simulation request", "error", err)
-	//	os.Exit(1)
-	//}
-	//scenarios, err := util.ReadScenarios(filepath.Join("client", "assets", "scenarios.json"))
-	//scenarios, err := util.ReadScenarios(filepath.Join("client", "assets", "garden-i034796-g2-db-replay.json"))
-	//scenarios, err := util.ReadScenarios("/Users/i062009/go/src/github.com/elankath/poc/db/scenarios/garden-i034796-g2-db-replay.json")
-	scenario, err := util.ReadScenario("/Users/I749592/go/src/github.com/elankath/gardener-scaling-reports/live_hc-eu30_prod-gc-dmi_ca-replay-3.json")
-	dieOnError(err)
-	clusterSnapshot := scenario.ClusterSnapshot
-	dieOnError(err)
-	recommendation, err := runSimulation(clusterSnapshot)
-	if err != nil {
-		fmt.Println(err)
-	}
-	//caRecommendation := util.ExtractCAScaleUpRecommendation(scenarios[i].NodeGroups)
-	prettyPrint(recommendation, "recommendation: ")
-	//prettyPrint(caRecommendation, "CA recommendation: ")
-}
-
-func prettyPrint[T any](obj T, message string) {
-	jsonBytes, err := json.MarshalIndent(obj, "", "\t")
-	dieOnError(err)
-	fmt.Printf("%s:%s\n", message, string(jsonBytes))
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			dieOnError(c.run(os.Args[2:]))
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	_, _ = fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", filepath.Base(os.Args[0]))
 }
 
 func dieOnError(err error) {
@@ -54,47 +83,238 @@ func dieOnError(err error) {
 	}
 }
 
-func runSimulation(clusterSnapshot gsc.ClusterSnapshot) (*api.RecommendationResponse, error) {
-	reqURL := "http://localhost:8080/recommend/"
-	reqBytes, err := json.Marshal(clusterSnapshot)
+func addServerFlags(fs *flag.FlagSet) *serverFlags {
+	sf := &serverFlags{}
+	fs.StringVar(&sf.url, "url", "http://localhost:8080", "base URL of the scaling recommender")
+	fs.StringVar(&sf.token, "token", "", "bearer token sent to the scaling recommender, taken from $"+authTokenEnv+" if not set")
+	fs.StringVar(&sf.caFile, "ca-file", "", "path to the CA bundle which signed the serving certificate of the recommender, the system CAs if empty")
+	fs.BoolVar(&sf.insecureSkipTLSVerify, "insecure-skip-tls-verify", false, "do not verify the serving certificate of the recommender")
+	fs.StringVar(&sf.strategy, "strategy", "", "scoring strategy used by the recommender, its default strategy if empty")
+	fs.DurationVar(&sf.timeout, "timeout", 0, "timeout of a recommendation, the default timeout of the recommender if zero")
+	addOutputFlag(fs, &sf.output)
+	return sf
+}
+
+func addOutputFlag(fs *flag.FlagSet, output *string) {
+	fs.StringVar(output, "output", string(util.OutputTable), fmt.Sprintf("output format, one of %v", util.OutputFormats))
+}
+
+func (sf *serverFlags) authToken() string {
+	if sf.token != "" {
+		return sf.token
+	}
+	return os.Getenv(authTokenEnv)
+}
+
+// newClient returns a client of the recommender. Its timeout leaves the recommender time to return a partial
+// recommendation once its own timeout expired.
+func (sf *serverFlags) newClient() (*client.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: sf.insecureSkipTLSVerify}
+	if sf.caFile != "" {
+		caPEM, err := os.ReadFile(sf.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %q", sf.caFile)
+		}
+	}
+	timeout := defaultRequestTimeout
+	if sf.timeout > 0 {
+		timeout = sf.timeout + time.Minute
+	}
+	return client.New(sf.url, client.WithBearerToken(sf.authToken()), client.WithTLSConfig(tlsConfig), client.WithTimeout(timeout))
+}
+
+func (sf *serverFlags) runOptions() client.RunOptions {
+	return client.RunOptions{Strategy: sf.strategy, Timeout: sf.timeout}
+}
+
+// recommendSnapshot sends the cluster snapshot of a scaling-history scenario with its own pods to the recommender.
+func (sf *serverFlags) recommendSnapshot(s *scalehist.Scenario) (*api.RecommendationResponse, error) {
+	c, err := sf.newClient()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
-	r := bytes.NewReader(reqBytes)
-	request, err := http.NewRequest(http.MethodPost, reqURL, r)
+	opts := sf.runOptions()
+	opts.PodSource = api.PodSourceSnapshot
+	return c.Recommend(context.Background(), &api.ClusterSnapshot{ClusterSnapshot: s.ClusterSnapshot}, opts)
+}
 
+// parseFlags parses the flags of a command, scenario is required if it is not nil.
+func parseFlags(fs *flag.FlagSet, args []string, scenarioPath, output *string) (util.OutputFormat, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if scenarioPath != nil && *scenarioPath == "" {
+		return "", fmt.Errorf("%s: scenario is required", fs.Name())
+	}
+	return util.ParseOutputFormat(*output)
+}
+
+func runRecommend(args []string) error {
+	fs := flag.NewFlagSet("recommend", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "path to a scaling-history scenario")
+	sf := addServerFlags(fs)
+	format, err := parseFlags(fs, args, scenarioPath, &sf.output)
 	if err != nil {
-		fmt.Printf("Error creating request: %v", err)
-		os.Exit(1)
+		return err
 	}
-	request.Header.Set("Content-Type", "application/json")
-	client := http.Client{
-		Timeout: 10 * time.Minute,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	s, err := util.ReadScenario(*scenarioPath)
+	if err != nil {
+		return err
 	}
-	response, err := client.Do(request)
+	response, err := sf.recommendSnapshot(s)
 	if err != nil {
-		fmt.Printf("Error in executing request: %v", err)
-		os.Exit(1)
+		return err
 	}
-	defer func() {
-		_ = response.Body.Close()
-	}()
-	if response.StatusCode == http.StatusOK {
-		readBytes, err := io.ReadAll(response.Body)
-		if err != nil {
-			fmt.Printf("Error in reading response: %v", err)
-			os.Exit(1)
-		}
-		recommendationResponse := &api.RecommendationResponse{}
-		if err = json.Unmarshal(readBytes, recommendationResponse); err != nil {
-			fmt.Printf("Error in unmarshalling response: %v", err)
-			os.Exit(1)
+	return util.WriteOutput(os.Stdout, format, response, func(w io.Writer) error {
+		return util.WriteRecommendationTable(w, response)
+	})
+}
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "path to a scenario file")
+	sf := addServerFlags(fs)
+	format, err := parseFlags(fs, args, scenarioPath, &sf.output)
+	if err != nil {
+		return err
+	}
+	simRequest, err := scenario.LoadSimulationRequest(*scenarioPath)
+	if err != nil {
+		return err
+	}
+	c, err := sf.newClient()
+	if err != nil {
+		return err
+	}
+	response, err := c.Simulate(context.Background(), simRequest, sf.runOptions())
+	if err != nil {
+		return err
+	}
+	return util.WriteOutput(os.Stdout, format, response, func(w io.Writer) error {
+		return util.WriteRecommendationTable(w, response)
+	})
+}
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "path to a scaling-history scenario whose scale-up by cluster-autoscaler is compared with the recommendation")
+	provider := fs.String("provider", "aws", "provider whose pricing catalog is used to compute the cost of the scale-ups")
+	sf := addServerFlags(fs)
+	format, err := parseFlags(fs, args, scenarioPath, &sf.output)
+	if err != nil {
+		return err
+	}
+	pa, err := pricing.NewInstancePricingAccess(*provider)
+	if err != nil {
+		return err
+	}
+	s, err := util.ReadScenario(*scenarioPath)
+	if err != nil {
+		return err
+	}
+	response, err := sf.recommendSnapshot(s)
+	if err != nil {
+		return err
+	}
+	report := util.CompareWithClusterAutoscaler(s, response, pa)
+	return util.WriteOutput(os.Stdout, format, report, func(w io.Writer) error {
+		return util.WriteComparisonReport(w, report)
+	})
+}
+
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	batchPath := fs.String("scenario", "", "path to a scenario file, scaling history report or directory of them which are run as a batch")
+	provider := fs.String("provider", "aws", "provider whose pricing catalog is used to compute the cost of the scale-ups")
+	parallelism := fs.Int("parallelism", 1, "number of scenarios which are sent concurrently, the recommender still runs them one at a time")
+	reportDir := fs.String("report-dir", ".", "directory to which batch-report.csv and batch-report.md are written")
+	sf := addServerFlags(fs)
+	format, err := parseFlags(fs, args, batchPath, &sf.output)
+	if err != nil {
+		return err
+	}
+	if *parallelism > 1 && sf.timeout > 0 {
+		// the timeout of a recommendation includes the time it waits for the recommendations before it.
+		return fmt.Errorf("%s: -parallelism greater than 1 cannot be combined with -timeout as the recommender runs one scenario at a time", fs.Name())
+	}
+	pa, err := pricing.NewInstancePricingAccess(*provider)
+	if err != nil {
+		return err
+	}
+	scenarios, err := util.LoadBatchScenarios(*batchPath)
+	if err != nil {
+		return err
+	}
+	c, err := sf.newClient()
+	if err != nil {
+		return err
+	}
+	simulate := func(ctx context.Context, simRequest *api.SimulationRequest) (*api.RecommendationResponse, error) {
+		return c.Simulate(ctx, simRequest, sf.runOptions())
+	}
+	log.Printf("running %d scenarios with parallelism %d", len(scenarios), *parallelism)
+	results := util.RunBatch(context.Background(), scenarios, *parallelism, simulate, pa)
+	if err = os.MkdirAll(*reportDir, 0755); err != nil {
+		return err
+	}
+	if err = writeReport(filepath.Join(*reportDir, "batch-report.csv"), results, util.WriteBatchReportCSV); err != nil {
+		return err
+	}
+	if err = writeReport(filepath.Join(*reportDir, "batch-report.md"), results, util.WriteBatchReportMarkdown); err != nil {
+		return err
+	}
+	return util.WriteOutput(os.Stdout, format, results, func(w io.Writer) error {
+		return util.WriteBatchTable(w, results)
+	})
+}
+
+func runPricing(args []string) error {
+	fs := flag.NewFlagSet("pricing", flag.ExitOnError)
+	provider := fs.String("provider", "aws", "provider whose pricing catalog is listed")
+	instanceTypes := fs.String("instance-types", "", "comma separated instance types to list, all if empty")
+	var output string
+	addOutputFlag(fs, &output)
+	format, err := parseFlags(fs, args, nil, &output)
+	if err != nil {
+		return err
+	}
+	pa, err := pricing.NewInstancePricingAccess(*provider)
+	if err != nil {
+		return err
+	}
+	allPricing := pa.ListInstancePricing()
+	if *instanceTypes != "" {
+		names := strings.Split(*instanceTypes, ",")
+		allPricing = make([]pricing.InstancePricing, 0, len(names))
+		for _, name := range names {
+			instancePricing, ok := pa.GetInstancePricing(strings.TrimSpace(name))
+			if !ok {
+				return fmt.Errorf("instance type %q is not in the pricing catalog of %s", name, *provider)
+			}
+			allPricing = append(allPricing, instancePricing)
 		}
-		return recommendationResponse, nil
 	}
-	return nil, fmt.Errorf("failed simulation: %s, StatusCode: %d, Status:%s", clusterSnapshot.ID, response.StatusCode, response.Status)
+	return util.WriteOutput(os.Stdout, format, allPricing, func(w io.Writer) error {
+		return util.WritePricingTable(w, allPricing)
+	})
+}
+
+func writeReport(filePath string, results []util.BatchResult, write func(io.Writer, []util.BatchResult) error) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err = write(f, results); err != nil {
+		_ = f.Close()
+		return err
+	}
+	log.Printf("wrote %s", filePath)
+	return f.Close()
 }
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/pricing"
+	"unmarshall/scaling-recommender/scenario"
+)
+
+// batchReportHeader is the header of the CSV and Markdown batch reports.
+var batchReportHeader = []string{"Scenario", "Recommended nodes", "Monthly cost", "Unscheduled pods", "Run time", "Error"}
+
+// BatchScenario is a simulation request which is run as part of a batch. Err is set if the scenario could not be loaded,
+// it is then reported instead of being run.
+type BatchScenario struct {
+	Name    string
+	Request *api.SimulationRequest
+	Err     error
+}
+
+// SimulateFunc sends a simulation request to the recommender and returns its recommendation.
+type SimulateFunc func(ctx context.Context, simRequest *api.SimulationRequest) (*api.RecommendationResponse, error)
+
+// BatchResult is the outcome of a scenario of a batch run.
+type BatchResult struct {
+	Scenario         string  `json:"scenario"`
+	RecommendedNodes int     `json:"recommendedNodes"`
+	MonthlyCost      float64 `json:"monthlyCost"`
+	UnscheduledPods  int     `json:"unscheduledPods"`
+	RunTime          string  `json:"runTime"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// LoadBatchScenarios loads the scenarios at path, which is either a single file or a directory whose JSON files are
+// loaded in lexical order. A file is either a scenario file or a scaling history report like client/assets/scenarios.json,
+// every scenario of a report is run on its own.
+func LoadBatchScenarios(path string) ([]BatchScenario, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadBatchScenarioFile(path)
+	}
+	// filepath.Glob returns the matches in lexical order.
+	filePaths, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var scenarios []BatchScenario
+	for _, filePath := range filePaths {
+		fileScenarios, err := loadBatchScenarioFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, fileScenarios...)
+	}
+	return scenarios, nil
+}
+
+// RunBatch runs the scenarios with simulate, at most parallelism of them concurrently. The recommender runs one
+// recommendation at a time, so concurrent scenarios wait for each other on the server. The results are in the order of
+// the scenarios, a scenario which failed is reported with its error. Costs are the 3-year reserved prices of the catalog.
+func RunBatch(ctx context.Context, scenarios []BatchScenario, parallelism int, simulate SimulateFunc, pa pricing.InstancePricingAccess) []BatchResult {
+	results := make([]BatchResult, len(scenarios))
+	sem := make(chan struct{}, max(parallelism, 1))
+	var wg sync.WaitGroup
+	for i, s := range scenarios {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runBatchScenario(ctx, simulate, s, pa)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// WriteBatchReportCSV writes the results as CSV.
+func WriteBatchReportCSV(w io.Writer, results []BatchResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(batchReportHeader); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := cw.Write(batchReportRow(result)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteBatchReportMarkdown writes the results as a Markdown table followed by the totals of the batch.
+func WriteBatchReportMarkdown(w io.Writer, results []BatchResult) error {
+	var sb strings.Builder
+	sb.WriteString("| " + strings.Join(batchReportHeader, " | ") + " |\n")
+	sb.WriteString("|" + strings.Repeat(" --- |", len(batchReportHeader)) + "\n")
+	var nodes, unscheduledPods, failed int
+	var cost float64
+	for _, result := range results {
+		row := batchReportRow(result)
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if result.Error != "" {
+			failed++
+			continue
+		}
+		nodes += result.RecommendedNodes
+		unscheduledPods += result.UnscheduledPods
+		cost += result.MonthlyCost
+	}
+	_, _ = fmt.Fprintf(&sb, "\n%d scenarios, %d failed: %d recommended nodes, monthly cost %.2f, %d unscheduled pods\n", len(results), failed, nodes, cost, unscheduledPods)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func loadBatchScenarioFile(filePath string) ([]BatchScenario, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	if !isHistoryReport(data) {
+		s, err := scenario.Parse(data)
+		if err != nil {
+			return []BatchScenario{{Name: name, Err: fmt.Errorf("cannot parse scenario %q: %w", filePath, err)}}, nil
+		}
+		request, err := s.ToSimulationRequest()
+		return []BatchScenario{{Name: name, Request: request, Err: err}}, nil
+	}
+	report := &HistoryReport{}
+	if err = json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("cannot decode scaling history report %q: %w", filePath, err)
+	}
+	if report.Name != "" {
+		name = report.Name
+	}
+	scenarios := make([]BatchScenario, 0, len(report.Scenarios))
+	for i, hs := range report.Scenarios {
+		id := fmt.Sprintf("%s-%d", name, i)
+		request, err := hs.ToSimulationRequest(id)
+		scenarios = append(scenarios, BatchScenario{Name: id, Request: request, Err: err})
+	}
+	return scenarios, nil
+}
+
+// isHistoryReport checks if data is a scaling history report, which has a top-level Scenarios field unlike a scenario file.
+func isHistoryReport(data []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["Scenarios"]
+	return ok
+}
+
+func runBatchScenario(ctx context.Context, simulate SimulateFunc, s BatchScenario, pa pricing.InstancePricingAccess) BatchResult {
+	result := BatchResult{Scenario: s.Name}
+	if s.Err != nil {
+		result.Error = s.Err.Error()
+		return result
+	}
+	response, err := simulate(ctx, s.Request)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	summary := summariseScaleUp(response.Recommendation.ScaleUp, len(response.UnscheduledPods), pa)
+	result.RecommendedNodes = summary.Nodes
+	result.MonthlyCost = summary.MonthlyCost
+	result.UnscheduledPods = summary.UnscheduledPods
+	result.RunTime = response.RunTime
+	return result
+}
+
+func batchReportRow(result BatchResult) []string {
+	if result.Error != "" {
+		return []string{result.Scenario, "", "", "", "", result.Error}
+	}
+	return []string{
+		result.Scenario,
+		strconv.Itoa(result.RecommendedNodes),
+		strconv.FormatFloat(result.MonthlyCost, 'f', 2, 64),
+		strconv.Itoa(result.UnscheduledPods),
+		result.RunTime,
+		"",
+	}
+}
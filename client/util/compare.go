@@ -0,0 +1,164 @@
+package util
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	scalehist "github.com/elankath/gardener-scaling-history"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/pricing"
+)
+
+// priorityExpander is the name of the cluster-autoscaler expander which picks node groups by configured priorities.
+const priorityExpander = "priority"
+
+// ComparisonReport compares the scale-up recommended for a scaling-history scenario with the scale-up which
+// cluster-autoscaler actually performed.
+type ComparisonReport struct {
+	ScenarioID        string         `json:"scenarioID"`
+	Recommender       ScaleUpSummary `json:"recommender"`
+	ClusterAutoscaler ScaleUpSummary `json:"clusterAutoscaler"`
+	// MonthlyCostDifference is the monthly cost of the recommender minus the monthly cost of cluster-autoscaler, a
+	// negative difference is a saving.
+	MonthlyCostDifference float64 `json:"monthlyCostDifference"`
+	// PriorityExpander is set if cluster-autoscaler used the priority expander. It then picked node groups by their
+	// configured priority instead of their cost, so the costs are not comparable.
+	PriorityExpander bool `json:"priorityExpander"`
+	// Incomplete is set if nodes of either scale-up have an instance type which is unknown or not in the pricing catalog.
+	// Their cost is missing from the monthly costs and their difference.
+	Incomplete bool `json:"incomplete"`
+}
+
+// ScaleUpSummary summarises a scale-up.
+type ScaleUpSummary struct {
+	ScaleUp []api.ScaleUpRecommendation `json:"scaleUp"`
+	Nodes   int                         `json:"nodes"`
+	// NodesPerInstanceType is the number of added nodes per instance type.
+	NodesPerInstanceType map[string]int `json:"nodesPerInstanceType"`
+	// MonthlyCost is the monthly price of the added nodes, as listed in the pricing catalog.
+	MonthlyCost float64 `json:"monthlyCost"`
+	// UnpricedInstanceTypes are the instance types of added nodes which are not in the pricing catalog, sorted. An empty
+	// instance type stands for node pools whose instance type is unknown.
+	UnpricedInstanceTypes []string `json:"unpricedInstanceTypes,omitempty"`
+	UnscheduledPods       int      `json:"unscheduledPods"`
+}
+
+// CompareWithClusterAutoscaler compares the recommendation computed for the cluster snapshot of the scenario with the
+// scale-up of cluster-autoscaler recorded in its scaling result. Costs are the 3-year reserved prices of the catalog,
+// which is what the cost-only scorer minimises.
+func CompareWithClusterAutoscaler(scenario *scalehist.Scenario, response *api.RecommendationResponse, pa pricing.InstancePricingAccess) ComparisonReport {
+	caScaleUp := ExtractCAScaleUpRecommendation(scenario)
+	report := ComparisonReport{
+		ScenarioID:        scenario.ClusterSnapshot.ID,
+		Recommender:       summariseScaleUp(response.Recommendation.ScaleUp, len(response.UnscheduledPods), pa),
+		ClusterAutoscaler: summariseScaleUp(caScaleUp, len(scenario.ScalingResult.PendingUnscheduledPods), pa),
+		PriorityExpander:  usesPriorityExpander(scenario.ClusterSnapshot.AutoscalerConfig.CASettings.Expander),
+	}
+	report.MonthlyCostDifference = report.Recommender.MonthlyCost - report.ClusterAutoscaler.MonthlyCost
+	report.Incomplete = len(report.Recommender.UnpricedInstanceTypes) > 0 || len(report.ClusterAutoscaler.UnpricedInstanceTypes) > 0
+	return report
+}
+
+// ExtractCAScaleUpRecommendation converts the node groups scaled up by cluster-autoscaler in the scenario into scale-up
+// recommendations. The instance type of a node group is the machine type of its worker pool.
+func ExtractCAScaleUpRecommendation(scenario *scalehist.Scenario) []api.ScaleUpRecommendation {
+	cs := scenario.ClusterSnapshot
+	recommendations := make([]api.ScaleUpRecommendation, 0, len(scenario.ScalingResult.ScaledUpNodeGroups))
+	for nodeGroupName, incrementBy := range scenario.ScalingResult.ScaledUpNodeGroups {
+		if incrementBy <= 0 {
+			continue
+		}
+		rec := api.ScaleUpRecommendation{NodePoolName: nodeGroupName, IncrementBy: int32(incrementBy)}
+		if ng, ok := cs.AutoscalerConfig.NodeGroups[nodeGroupName]; ok {
+			rec.NodePoolName = ng.PoolName
+			rec.Zone = ng.Zone
+		}
+		for _, wp := range cs.WorkerPools {
+			if wp.Name == rec.NodePoolName {
+				rec.InstanceType = wp.MachineType
+				break
+			}
+		}
+		recommendations = append(recommendations, rec)
+	}
+	slices.SortFunc(recommendations, func(a, b api.ScaleUpRecommendation) int {
+		return cmp.Or(strings.Compare(a.NodePoolName, b.NodePoolName), strings.Compare(a.Zone, b.Zone))
+	})
+	return recommendations
+}
+
+// WriteComparisonReport writes the report as a table.
+func WriteComparisonReport(w io.Writer, report ComparisonReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Scenario:\t%s\n", report.ScenarioID)
+	_, _ = fmt.Fprintln(tw, "\tRecommender\tCluster-Autoscaler")
+	_, _ = fmt.Fprintf(tw, "Nodes\t%d\t%d\n", report.Recommender.Nodes, report.ClusterAutoscaler.Nodes)
+	_, _ = fmt.Fprintf(tw, "Instance types\t%s\t%s\n", formatNodesPerInstanceType(report.Recommender.NodesPerInstanceType), formatNodesPerInstanceType(report.ClusterAutoscaler.NodesPerInstanceType))
+	_, _ = fmt.Fprintf(tw, "Monthly cost\t%.2f\t%.2f\n", report.Recommender.MonthlyCost, report.ClusterAutoscaler.MonthlyCost)
+	_, _ = fmt.Fprintf(tw, "Unscheduled pods\t%d\t%d\n", report.Recommender.UnscheduledPods, report.ClusterAutoscaler.UnscheduledPods)
+	_, _ = fmt.Fprintf(tw, "Monthly cost difference:\t%.2f\n", report.MonthlyCostDifference)
+	if report.PriorityExpander {
+		_, _ = fmt.Fprintln(tw, "WARNING:\tcluster-autoscaler used the priority expander, its choice is not driven by cost")
+	}
+	if report.Incomplete {
+		_, _ = fmt.Fprintf(tw, "WARNING:\tcosts are incomplete, instance types without price: recommender %s, cluster-autoscaler %s\n",
+			formatInstanceTypes(report.Recommender.UnpricedInstanceTypes), formatInstanceTypes(report.ClusterAutoscaler.UnpricedInstanceTypes))
+	}
+	return tw.Flush()
+}
+
+func summariseScaleUp(recommendations []api.ScaleUpRecommendation, unscheduledPods int, pa pricing.InstancePricingAccess) ScaleUpSummary {
+	summary := ScaleUpSummary{
+		ScaleUp:              recommendations,
+		NodesPerInstanceType: make(map[string]int),
+		UnscheduledPods:      unscheduledPods,
+	}
+	for _, rec := range recommendations {
+		summary.Nodes += int(rec.IncrementBy)
+		summary.NodesPerInstanceType[rec.InstanceType] += int(rec.IncrementBy)
+		if _, ok := pa.GetInstancePricing(rec.InstanceType); !ok {
+			if !slices.Contains(summary.UnpricedInstanceTypes, rec.InstanceType) {
+				summary.UnpricedInstanceTypes = append(summary.UnpricedInstanceTypes, rec.InstanceType)
+			}
+			continue
+		}
+		summary.MonthlyCost += float64(rec.IncrementBy) * pa.Get3YearReservedPricing(rec.InstanceType)
+	}
+	slices.Sort(summary.UnpricedInstanceTypes)
+	return summary
+}
+
+// usesPriorityExpander checks the expander setting of cluster-autoscaler, which may list several expanders.
+func usesPriorityExpander(expander string) bool {
+	return slices.ContainsFunc(strings.Split(expander, ","), func(e string) bool {
+		return strings.TrimSpace(e) == priorityExpander
+	})
+}
+
+func formatInstanceTypes(instanceTypes []string) string {
+	if len(instanceTypes) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(instanceTypes))
+	for _, instanceType := range instanceTypes {
+		parts = append(parts, cmp.Or(instanceType, "unknown"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatNodesPerInstanceType(nodesPerInstanceType map[string]int) string {
+	if len(nodesPerInstanceType) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(nodesPerInstanceType))
+	for instanceType, nodes := range nodesPerInstanceType {
+		parts = append(parts, fmt.Sprintf("%dx %s", nodes, instanceType))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ", ")
+}
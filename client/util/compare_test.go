@@ -0,0 +1,32 @@
+package util
+
+import (
+	"slices"
+	"testing"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/pricing"
+)
+
+func TestSummariseScaleUpReportsUnpricedInstanceTypes(t *testing.T) {
+	pa, err := pricing.NewInstancePricingAccess("aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summariseScaleUp([]api.ScaleUpRecommendation{
+		{NodePoolName: "p1", InstanceType: "m5.large", IncrementBy: 2},
+		{NodePoolName: "p2", InstanceType: "x9.unknown", IncrementBy: 1},
+		{NodePoolName: "p3", IncrementBy: 1},
+		{NodePoolName: "p4", InstanceType: "x9.unknown", IncrementBy: 1},
+	}, 0, pa)
+
+	if want := []string{"", "x9.unknown"}; !slices.Equal(summary.UnpricedInstanceTypes, want) {
+		t.Errorf("got unpriced instance types %q, want %q", summary.UnpricedInstanceTypes, want)
+	}
+	if want := 2 * pa.Get3YearReservedPricing("m5.large"); summary.MonthlyCost != want {
+		t.Errorf("got monthly cost %.2f, want %.2f of the priced nodes", summary.MonthlyCost, want)
+	}
+	if summary.Nodes != 5 {
+		t.Errorf("got %d nodes, want 5", summary.Nodes)
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/scenario"
+)
+
+// HistoryReport is a recorded scaling history of a shoot as found in client/assets/scenarios.json. Every scenario is a
+// point in time at which pods were pending.
+type HistoryReport struct {
+	Name      string            `json:"Name"`
+	Scenarios []HistoryScenario `json:"Scenarios"`
+}
+
+// HistoryScenario is a recorded state of the shoot.
+type HistoryScenario struct {
+	StartTime       time.Time          `json:"StartTime"`
+	UnscheduledPods []HistoryPod       `json:"UnscheduledPods"`
+	ScheduledPods   []HistoryPod       `json:"ScheduledPods"`
+	NodeGroups      []HistoryNodeGroup `json:"NodeGroups"`
+	Nodes           []HistoryNode      `json:"Nodes"`
+}
+
+// HistoryPod is a recorded pod.
+type HistoryPod struct {
+	Name      string              `json:"Name"`
+	Namespace string              `json:"Namespace"`
+	NodeName  string              `json:"NodeName"`
+	Labels    map[string]string   `json:"Labels"`
+	Requests  corev1.ResourceList `json:"Requests"`
+	Spec      corev1.PodSpec      `json:"Spec"`
+}
+
+// HistoryNodeGroup is a recorded node group of cluster-autoscaler, which covers a worker pool in one zone.
+type HistoryNodeGroup struct {
+	Name        string `json:"Name"`
+	CurrentSize int    `json:"CurrentSize"`
+	TargetSize  int    `json:"TargetSize"`
+	Zone        string `json:"Zone"`
+	MachineType string `json:"MachineType"`
+	PoolName    string `json:"PoolName"`
+	PoolMax     int    `json:"PoolMax"`
+}
+
+// HistoryNode is a recorded node.
+type HistoryNode struct {
+	Name        string              `json:"Name"`
+	Labels      map[string]string   `json:"Labels"`
+	Taints      []corev1.Taint      `json:"Taints"`
+	Allocatable corev1.ResourceList `json:"Allocatable"`
+	Capacity    corev1.ResourceList `json:"Capacity"`
+}
+
+// ToSimulationRequest converts the recorded state into a simulation request with the given id. It is expressed as a
+// scenario.Scenario first, so that it is validated and node templates are derived from the recorded nodes like for
+// scenario files. Pods without requests are left out as they do not influence the recommendation.
+func (h HistoryScenario) ToSimulationRequest(id string) (*api.SimulationRequest, error) {
+	s := &scenario.Scenario{Version: scenario.CurrentVersion, ID: id}
+	poolIndex := make(map[string]int)
+	for _, ng := range h.NodeGroups {
+		i, ok := poolIndex[ng.PoolName]
+		if !ok {
+			i = len(s.NodePools)
+			poolIndex[ng.PoolName] = i
+			s.NodePools = append(s.NodePools, scenario.NodePool{Name: ng.PoolName, Max: int32(ng.PoolMax), InstanceType: ng.MachineType})
+		}
+		if !slices.Contains(s.NodePools[i].Zones, ng.Zone) {
+			s.NodePools[i].Zones = append(s.NodePools[i].Zones, ng.Zone)
+		}
+		s.NodePools[i].Current += int32(ng.CurrentSize)
+	}
+	nodeRefs := make(map[string]api.NodeReference, len(h.Nodes))
+	for _, n := range h.Nodes {
+		s.Nodes = append(s.Nodes, scenario.Node{
+			Name:        n.Name,
+			Labels:      n.Labels,
+			Taints:      n.Taints,
+			Allocatable: n.Allocatable,
+			Capacity:    n.Capacity,
+		})
+		nodeRefs[n.Name] = api.NodeReference{Name: n.Name, PoolName: n.Labels[common.WorkerPoolLabelKey], Zone: n.Labels[corev1.LabelTopologyZone]}
+	}
+	for _, p := range slices.Concat(h.ScheduledPods, h.UnscheduledPods) {
+		if len(p.Requests) == 0 {
+			continue
+		}
+		pod := scenario.Pod{
+			NamePrefix:                p.Name,
+			Labels:                    p.Labels,
+			Requests:                  p.Requests,
+			TopologySpreadConstraints: p.Spec.TopologySpreadConstraints,
+			NodeSelector:              p.Spec.NodeSelector,
+			Affinity:                  p.Spec.Affinity,
+			Tolerations:               p.Spec.Tolerations,
+		}
+		if p.NodeName != "" {
+			// pods on nodes which were not recorded cannot be placed.
+			ref, ok := nodeRefs[p.NodeName]
+			if !ok {
+				continue
+			}
+			pod.ScheduledOn = &ref
+		}
+		s.Pods = append(s.Pods, pod)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("recorded scenario %q is invalid: %w", id, err)
+	}
+	return s.ToSimulationRequest()
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/pricing"
+)
+
+// OutputFormat is the format in which the client writes results.
+type OutputFormat string
+
+const (
+	// OutputTable writes results as a human-readable table.
+	OutputTable OutputFormat = "table"
+	// OutputJSON writes results as indented JSON.
+	OutputJSON OutputFormat = "json"
+	// OutputYAML writes results as YAML.
+	OutputYAML OutputFormat = "yaml"
+)
+
+// OutputFormats lists the supported output formats.
+var OutputFormats = []OutputFormat{OutputTable, OutputJSON, OutputYAML}
+
+// ParseOutputFormat parses the name of an output format.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	format := OutputFormat(name)
+	if !slices.Contains(OutputFormats, format) {
+		return "", fmt.Errorf("unsupported output format %q, supported formats are %v", name, OutputFormats)
+	}
+	return format, nil
+}
+
+// WriteOutput writes v in the given format, writeTable writes it as a table.
+func WriteOutput(w io.Writer, format OutputFormat, v any, writeTable func(io.Writer) error) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case OutputYAML:
+		return writeYAML(w, v)
+	default:
+		return writeTable(w)
+	}
+}
+
+// WriteRecommendationTable writes the scale-up of the response, its unscheduled pods and run time as a table.
+func WriteRecommendationTable(w io.Writer, response *api.RecommendationResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NODE POOL\tZONE\tINSTANCE TYPE\tINCREMENT")
+	for _, rec := range response.Recommendation.ScaleUp {
+		instanceType := rec.InstanceType
+		if rec.SyntheticTemplate {
+			instanceType += " (synthetic)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", rec.NodePoolName, rec.Zone, instanceType, rec.IncrementBy)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	unscheduledPods := make([]string, 0, len(response.UnscheduledPods))
+	for _, key := range response.UnscheduledPods {
+		unscheduledPods = append(unscheduledPods, key.String())
+	}
+	_, _ = fmt.Fprintf(w, "\nUnscheduled pods: %d\n", len(unscheduledPods))
+	for _, pod := range unscheduledPods {
+		_, _ = fmt.Fprintf(w, "  %s\n", pod)
+	}
+	_, _ = fmt.Fprintf(w, "Run time: %s\n", response.RunTime)
+	if response.Partial {
+		_, _ = fmt.Fprintf(w, "WARNING: the recommendation is partial: %s\n", response.PartialReason)
+	}
+	return nil
+}
+
+// WritePricingTable writes the catalog entries as a table.
+func WritePricingTable(w io.Writer, allPricing []pricing.InstancePricing) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "INSTANCE TYPE\tVCPU\tMEMORY (GIB)\tON DEMAND\t1Y RESERVED\t3Y RESERVED")
+	for _, p := range allPricing {
+		_, _ = fmt.Fprintf(tw, "%s\t%g\t%g\t%.2f\t%.2f\t%.2f\n", p.InstanceType, p.VCpu, p.Memory, p.EDPPrice.PayAsYouGo, p.EDPPrice.Reserved1Year, p.EDPPrice.Reserved3Year)
+	}
+	return tw.Flush()
+}
+
+// WriteBatchTable writes the results of a batch run as a table.
+func WriteBatchTable(w io.Writer, results []BatchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, strings.ToUpper(strings.Join(batchReportHeader, "\t")))
+	for _, result := range results {
+		_, _ = fmt.Fprintln(tw, strings.Join(batchReportRow(result), "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeYAML writes v as YAML with the field names of its JSON encoding, the keys of mappings are sorted.
+func writeYAML(w io.Writer, v any) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
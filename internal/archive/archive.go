@@ -0,0 +1,219 @@
+// Package archive stores the inputs and results of recommender runs on disk so that they can be inspected later.
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+const (
+	summaryFileName         = "run.json"
+	inputFileName           = "input.json"
+	scoresFileName          = "scores.json"
+	nodeUtilisationFileName = "node-utilisation.json"
+	responseFileName        = "response.json"
+
+	runIDTimeFormat = "20060102-150405"
+)
+
+// ErrRunNotFound is returned if there is no archived run with the requested ID.
+var ErrRunNotFound = errors.New("run not found")
+
+var runIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// NodeUtilisation is the content of the node utilisation artifact of a run.
+type NodeUtilisation struct {
+	NodeUtilInfos   map[string]api.NodeUtilisationInfo `json:"node_util_infos,omitempty"`
+	UnscheduledPods []string                           `json:"unscheduled_pods,omitempty"`
+}
+
+// Archive stores every run in its own directory below dir.
+type Archive struct {
+	dir string
+}
+
+// New creates an Archive in dir, creating the directory if it does not exist.
+func New(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory %s: %w", dir, err)
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Dir returns the directory of the archive.
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+// StartRun creates the directory of a new run for the request with the given ID and stores the input of the run.
+func (a *Archive) StartRun(requestID string, input any) (*RunWriter, error) {
+	suffix, err := util.GenerateRandomString(4)
+	if err != nil {
+		return nil, err
+	}
+	startedAt := time.Now().UTC()
+	id := startedAt.Format(runIDTimeFormat) + "-" + sanitize(requestID) + "-" + suffix
+	dir := filepath.Join(a.dir, id)
+	if err = os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	w := &RunWriter{
+		dir: dir,
+		summary: api.RunSummary{
+			ID:        id,
+			RequestID: requestID,
+			Status:    api.RunInProgress,
+			StartedAt: startedAt,
+		},
+	}
+	if err = w.writeSummary(); err != nil {
+		return nil, err
+	}
+	if err = writeJSONFile(filepath.Join(dir, inputFileName), input); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ListRuns returns the summaries of all archived runs, the most recent run first.
+func (a *Archive) ListRuns() ([]api.RunSummary, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]api.RunSummary, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		var summary api.RunSummary
+		if err = readJSONFile(filepath.Join(a.dir, e.Name(), summaryFileName), &summary); err != nil {
+			// not a run directory
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b api.RunSummary) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return summaries, nil
+}
+
+// GetRun returns the archived run with the given ID and all its artifacts.
+func (a *Archive) GetRun(id string) (*api.ArchivedRun, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, ErrRunNotFound
+	}
+	dir := filepath.Join(a.dir, id)
+	run := &api.ArchivedRun{}
+	if err := readJSONFile(filepath.Join(dir, summaryFileName), &run.RunSummary); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+	for fileName, target := range map[string]*json.RawMessage{
+		inputFileName:           &run.Input,
+		scoresFileName:          &run.Scores,
+		nodeUtilisationFileName: &run.NodeUtilisation,
+		responseFileName:        &run.Response,
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, fileName))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		*target = data
+	}
+	return run, nil
+}
+
+// RunWriter writes the artifacts of a single run.
+type RunWriter struct {
+	dir     string
+	summary api.RunSummary
+}
+
+// ID returns the ID of the run.
+func (w *RunWriter) ID() string {
+	return w.summary.ID
+}
+
+// WriteScores stores the scores of all candidates per round.
+func (w *RunWriter) WriteScores(scores []api.RunResultScores) error {
+	return writeJSONFile(filepath.Join(w.dir, scoresFileName), scores)
+}
+
+// WriteNodeUtilisation stores the utilisation of the recommended nodes.
+func (w *RunWriter) WriteNodeUtilisation(nodeUtilisation NodeUtilisation) error {
+	return writeJSONFile(filepath.Join(w.dir, nodeUtilisationFileName), nodeUtilisation)
+}
+
+// Finish stores the response of the run and marks the run as finished. If err is not nil the run is marked as failed.
+func (w *RunWriter) Finish(response *api.RecommendationResponse, err error) error {
+	finishedAt := time.Now().UTC()
+	w.summary.FinishedAt = &finishedAt
+	w.summary.Status = api.RunSucceeded
+	if err != nil {
+		w.summary.Status = api.RunFailed
+		w.summary.Error = err.Error()
+	}
+	if response != nil {
+		w.summary.Partial = response.Partial
+		if writeErr := writeJSONFile(filepath.Join(w.dir, responseFileName), response); writeErr != nil {
+			return writeErr
+		}
+	}
+	return w.writeSummary()
+}
+
+func (w *RunWriter) writeSummary() error {
+	return writeJSONFile(filepath.Join(w.dir, summaryFileName), w.summary)
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	// write to a temporary file first so that readers never see a partially written file.
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// sanitize turns a request ID into a string which is safe to use in a directory name.
+func sanitize(requestID string) string {
+	if requestID == "" {
+		return "run"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '_' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '-'
+	}, requestID)
+}
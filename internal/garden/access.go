@@ -6,9 +6,7 @@ import (
 
 	authenticationv1alpha1 "github.com/gardener/gardener/pkg/apis/authentication/v1alpha1"
 	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
-	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -184,45 +182,5 @@ func getMaxSystemComponentRequestsAcrossNodes(ctx context.Context, sa ShootAcces
 	if err != nil {
 		return nil, err
 	}
-	nodeSystemComponentResourceList := collectSystemComponentResourceRequestsByNode(pods)
-	maxResourceList := corev1.ResourceList{}
-	for _, r := range nodeSystemComponentResourceList {
-		for name, q := range r {
-			val, ok := maxResourceList[name]
-			if !ok {
-				maxResourceList[name] = q
-				continue
-			}
-			if val.Cmp(q) < 0 {
-				maxResourceList[name] = q
-			}
-		}
-	}
-	return maxResourceList, nil
-}
-
-func collectSystemComponentResourceRequestsByNode(pods []corev1.Pod) map[string]corev1.ResourceList {
-	podsByNode := lo.GroupBy(pods, func(pod corev1.Pod) string {
-		return pod.Spec.NodeName
-	})
-	nodeResourceRequests := make(map[string]corev1.ResourceList, len(podsByNode))
-	for nodeName, nodePods := range podsByNode {
-		nodeResourceRequests[nodeName] = sumResourceRequests(nodePods)
-	}
-	return nodeResourceRequests
-}
-
-func sumResourceRequests(pods []corev1.Pod) corev1.ResourceList {
-	var totalMemory resource.Quantity
-	var totalCPU resource.Quantity
-	for _, pod := range pods {
-		for _, container := range pod.Spec.Containers {
-			totalMemory.Add(util.NilOr(container.Resources.Requests.Memory(), resource.Quantity{}))
-			totalCPU.Add(util.NilOr(container.Resources.Requests.Cpu(), resource.Quantity{}))
-		}
-	}
-	return corev1.ResourceList{
-		corev1.ResourceMemory: totalMemory,
-		corev1.ResourceCPU:    totalCPU,
-	}
+	return util.GetMaxRequestsPerNode(pods), nil
 }
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "scaling_recommender"
+
+	// LabelStrategy is the scoring strategy used by the recommender.
+	LabelStrategy = "strategy"
+	// LabelResult is the outcome of a recommendation request.
+	LabelResult = "result"
+)
+
+const (
+	// ResultSuccess is the value of LabelResult for successful recommendations.
+	ResultSuccess = "success"
+	// ResultError is the value of LabelResult for failed recommendations.
+	ResultError = "error"
+	// ResultCancelled is the value of LabelResult for recommendations that were cancelled or timed out.
+	ResultCancelled = "cancelled"
+	// ResultCacheHit is the value of LabelResult for response cache lookups which found a cached response.
+	ResultCacheHit = "hit"
+	// ResultCacheMiss is the value of LabelResult for response cache lookups which did not find a cached response.
+	ResultCacheMiss = "miss"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// RecommendationRequests counts the recommendation requests by strategy and result.
+	RecommendationRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "recommendation_requests_total",
+		Help:      "Total number of recommendation requests.",
+	}, []string{LabelStrategy, LabelResult})
+
+	// RecommendationDuration observes the time taken to compute a recommendation.
+	RecommendationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "recommendation_duration_seconds",
+		Help:      "Time taken to compute a recommendation.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{LabelStrategy})
+
+	// RoundDuration observes the time taken by a single recommender round across all node pools.
+	RoundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "round_duration_seconds",
+		Help:      "Time taken by a single recommender round.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{LabelStrategy})
+
+	// NodePoolSimulationDuration observes the time taken to simulate a candidate node of a node pool within a round. It is not
+	// labelled with the node pool as pool names are chosen by the clients.
+	NodePoolSimulationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "node_pool_simulation_duration_seconds",
+		Help:      "Time taken to simulate a candidate node of a node pool within a recommender round.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{LabelStrategy})
+
+	// SchedulingEventsWaitDuration observes the time spent waiting for pod scheduling events.
+	SchedulingEventsWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduling_events_wait_duration_seconds",
+		Help:      "Time spent waiting for pod scheduling events of a simulation run.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{LabelStrategy})
+
+	// RecommendedNodes observes the number of nodes recommended per recommendation.
+	RecommendedNodes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "recommended_nodes",
+		Help:      "Number of nodes recommended per recommendation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{LabelStrategy})
+
+	// UnscheduledPods observes the number of pods which remain unscheduled after a recommendation.
+	UnscheduledPods = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "unscheduled_pods",
+		Help:      "Number of pods which remain unscheduled after a recommendation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{LabelStrategy})
+
+	// ResponseCacheLookups counts the lookups of the response cache by result.
+	ResponseCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "response_cache_lookups_total",
+		Help:      "Total number of response cache lookups.",
+	}, []string{LabelResult})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RecommendationRequests,
+		RecommendationDuration,
+		RoundDuration,
+		NodePoolSimulationDuration,
+		SchedulingEventsWaitDuration,
+		RecommendedNodes,
+		UnscheduledPods,
+		ResponseCacheLookups,
+	)
+}
+
+// Handler returns the http.Handler which serves all registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
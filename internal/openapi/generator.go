@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var (
+	versionedPackage = regexp.MustCompile(`^v\d+((alpha|beta)\d+)?$`)
+	jsonMarshaler    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// wellKnownTypes are types with a custom JSON representation.
+	wellKnownTypes = map[reflect.Type]func() *Schema{
+		reflect.TypeOf(time.Time{}):        dateTimeSchema,
+		reflect.TypeOf(metav1.Time{}):      dateTimeSchema,
+		reflect.TypeOf(metav1.MicroTime{}): dateTimeSchema,
+		reflect.TypeOf(time.Duration(0)): func() *Schema {
+			return &Schema{Type: "integer", Format: "int64", Description: "duration in nanoseconds"}
+		},
+		reflect.TypeOf(metav1.Duration{}):    func() *Schema { return &Schema{Type: "string", Description: "duration, e.g. 1m30s"} },
+		reflect.TypeOf(resource.Quantity{}):  func() *Schema { return &Schema{Type: "string", Description: "resource quantity, e.g. 500m or 2Gi"} },
+		reflect.TypeOf(intstr.IntOrString{}): func() *Schema { return &Schema{IntOrString: true} },
+		reflect.TypeOf(json.RawMessage{}):    func() *Schema { return &Schema{Description: "embedded JSON document"} },
+	}
+)
+
+func dateTimeSchema() *Schema {
+	return &Schema{Type: "string", Format: "date-time"}
+}
+
+// Generator derives schemas from Go types by reflection, following their JSON encoding. Every named struct type
+// becomes a component schema which is referenced wherever the type is used. Fields are not marked as required since
+// requests are checked by the validation of the server instead.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// NewGenerator creates an empty Generator.
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// SchemaOf returns the schema of the type of v.
+func (g *Generator) SchemaOf(v any) *Schema {
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+// Schemas returns all component schemas generated so far.
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+func (g *Generator) schemaFor(t reflect.Type) *Schema {
+	if newSchema, ok := wellKnownTypes[t]; ok {
+		return newSchema()
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaFor(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		// interfaces and other types can hold any value
+		return &Schema{}
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.objectSchema(t)
+	}
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	name := schemaName(t)
+	g.names[t] = name
+	if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+		g.schemas[name] = &Schema{Description: "custom JSON encoding of " + t.String()}
+	} else {
+		// register the name before generating the properties so that recursive types terminate.
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.objectSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *Generator) objectSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if f.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			// embedded structs are inlined by encoding/json
+			g.addFields(s, fieldType)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+	}
+}
+
+// schemaName qualifies the type name with its package, e.g. api.PodInfo or core.v1.PodSpec.
+func schemaName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if versionedPackage.MatchString(pkg) {
+		pkg = path.Base(path.Dir(t.PkgPath())) + "." + pkg
+	}
+	name := t.Name()
+	// instantiated generic types carry their type arguments in the name
+	if idx := strings.IndexByte(name, '['); idx >= 0 {
+		name = name[:idx]
+	}
+	return pkg + "." + name
+}
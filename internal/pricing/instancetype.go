@@ -0,0 +1,41 @@
+package pricing
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// ArchitectureAMD64 is the CPU architecture of x86-64 instance types.
+	ArchitectureAMD64 = "amd64"
+	// ArchitectureARM64 is the CPU architecture of ARM instance types, e.g. AWS Graviton.
+	ArchitectureARM64 = "arm64"
+)
+
+// armFamilies are the ARM instance families whose name does not follow the AWS convention of a g after the generation.
+var armFamilies = map[string]bool{"a1": true, "t2a": true, "c4a": true}
+
+// InstanceFamily returns the family of instanceType, e.g. m5 for the AWS type m5.large and n2 for the GCP type n2-standard-4.
+func InstanceFamily(instanceType string) string {
+	family, _, _ := strings.Cut(instanceType, ".")
+	family, _, _ = strings.Cut(family, "-")
+	return family
+}
+
+// InstanceArchitecture returns the CPU architecture of instanceType, which is derived from its family as the pricing catalog
+// does not list it. AWS marks Graviton families with a g after the generation, e.g. m6g or c7gn.
+func InstanceArchitecture(instanceType string) string {
+	family := InstanceFamily(instanceType)
+	if armFamilies[family] {
+		return ArchitectureARM64
+	}
+	generation := strings.IndexFunc(family, unicode.IsDigit)
+	if generation < 0 {
+		return ArchitectureAMD64
+	}
+	attributes := strings.TrimLeftFunc(family[generation:], unicode.IsDigit)
+	if strings.Contains(attributes, "g") {
+		return ArchitectureARM64
+	}
+	return ArchitectureAMD64
+}
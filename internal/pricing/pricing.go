@@ -1,10 +1,15 @@
 package pricing
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"slices"
+
+	"golang.org/x/exp/maps"
 
 	"k8s.io/apimachinery/pkg/util/json"
 )
@@ -17,6 +22,12 @@ var assets embed.FS
 type InstancePricingAccess interface {
 	Get3YearReservedPricing(instanceType string) float64
 	GetOnDemandPricing(instanceType string) float64
+	// GetInstancePricing returns the catalog entry of instanceType, which also lists its vCPUs and memory in GiB.
+	GetInstancePricing(instanceType string) (InstancePricing, bool)
+	// ListInstancePricing returns all entries of the catalog sorted by instance type.
+	ListInstancePricing() []InstancePricing
+	// Version identifies the loaded pricing catalog, it changes whenever the catalog changes.
+	Version() string
 }
 
 func NewInstancePricingAccess(provider string) (InstancePricingAccess, error) {
@@ -30,6 +41,7 @@ func NewInstancePricingAccess(provider string) (InstancePricingAccess, error) {
 type access struct {
 	provider   string
 	pricingMap map[string]InstancePricing
+	version    string
 }
 
 func (a *access) Get3YearReservedPricing(instanceType string) float64 {
@@ -50,31 +62,53 @@ func (a *access) GetOnDemandPricing(instanceType string) float64 {
 	return float64(price.EDPPrice.PayAsYouGo)
 }
 
+func (a *access) GetInstancePricing(instanceType string) (InstancePricing, bool) {
+	price, ok := a.pricingMap[instanceType]
+	return price, ok
+}
+
+func (a *access) ListInstancePricing() []InstancePricing {
+	instanceTypes := maps.Keys(a.pricingMap)
+	slices.Sort(instanceTypes)
+	allPricing := make([]InstancePricing, 0, len(instanceTypes))
+	for _, instanceType := range instanceTypes {
+		allPricing = append(allPricing, a.pricingMap[instanceType])
+	}
+	return allPricing
+}
+
+func (a *access) Version() string {
+	return a.version
+}
+
 func (a *access) initializeProviderPricing() (err error) {
 	switch a.provider {
 	case "aws":
-		a.pricingMap, err = loadInstancePricing(filepath.Join("assets", "aws_pricing_eu-west-1.json"))
+		a.pricingMap, a.version, err = loadInstancePricing(filepath.Join("assets", "aws_pricing_eu-west-1.json"))
 	case "gcp":
-		a.pricingMap, err = loadInstancePricing(filepath.Join("assets", "gcp_pricing_eu-west1.json"))
+		a.pricingMap, a.version, err = loadInstancePricing(filepath.Join("assets", "gcp_pricing_eu-west1.json"))
 	default:
 		err = fmt.Errorf("provider not supported: %s", a.provider)
 	}
 	return
 }
 
-func loadInstancePricing(pricingJsonPath string) (map[string]InstancePricing, error) {
+// loadInstancePricing loads the pricing catalog at pricingJsonPath. The version of the catalog is derived from its name and content.
+func loadInstancePricing(pricingJsonPath string) (map[string]InstancePricing, string, error) {
 	var allPricing AllInstancePricing
 	content, err := assets.ReadFile(pricingJsonPath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if err = json.Unmarshal(content, &allPricing); err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	checksum := sha256.Sum256(content)
+	version := filepath.Base(pricingJsonPath) + "@" + hex.EncodeToString(checksum[:8])
 
 	pricingMap := make(map[string]InstancePricing)
 	for _, pricing := range allPricing.Results {
 		pricingMap[pricing.InstanceType] = pricing
 	}
-	return pricingMap, nil
+	return pricingMap, version, nil
 }
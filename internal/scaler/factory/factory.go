@@ -4,8 +4,10 @@ import (
 	"log/slog"
 
 	kvclapi "github.com/unmarshall/kvcl/api"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"unmarshall/scaling-recommender/internal/scaler"
 	"unmarshall/scaling-recommender/internal/scaler/scaleup"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
 )
 
 type factory struct {
@@ -13,10 +15,10 @@ type factory struct {
 	appVersion string
 }
 
-func New(vcp kvclapi.ControlPlane, appVersion string, logger *slog.Logger) scaler.RecommenderFactory {
+func New(vcp kvclapi.ControlPlane, backend simulator.Backend, workers int, schedulerProfile *schedulerconfig.KubeSchedulerProfile, appVersion string, logger *slog.Logger) scaler.RecommenderFactory {
 	algos := make(map[scaler.AlgoVariant]scaler.Recommender)
 	// Register all scaling algorithms
-	algos[scaler.DefaultScaleUpAlgo] = scaleup.NewRecommender(vcp, appVersion, logger)
+	algos[scaler.DefaultScaleUpAlgo] = scaleup.NewRecommender(vcp, backend, workers, schedulerProfile, appVersion, logger)
 	return &factory{
 		algos:      algos,
 		appVersion: appVersion,
@@ -2,12 +2,10 @@ package scaleup
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"math/rand"
 	"slices"
 	"sync"
 	"time"
@@ -16,23 +14,25 @@ import (
 	v1 "k8s.io/api/scheduling/v1"
 
 	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/metrics"
 	"unmarshall/scaling-recommender/internal/pricing"
 	"unmarshall/scaling-recommender/internal/util"
 
 	"github.com/samber/lo"
 	"golang.org/x/exp/maps"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"unmarshall/scaling-recommender/api"
 	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
 
 	corev1 "k8s.io/api/core/v1"
 
 	kvclapi "github.com/unmarshall/kvcl/api"
-	kvcl "github.com/unmarshall/kvcl/pkg/control"
 )
 
 const (
@@ -40,31 +40,39 @@ const (
 	resourceNameFormat = "%s-sr-%s"
 )
 
-type recommender struct {
-	nc             kvclapi.NodeControl
-	pc             kvclapi.PodControl
-	ec             kvclapi.EventControl
-	pa             pricing.InstancePricingAccess
-	client         client.Client
-	scorer         scaler.Scorer
-	state          simulationState
-	nodeTemplates  map[string]gsc.NodeTemplate
-	appVersion     string
-	logger         *slog.Logger
-	resultLogsPath string
-}
+// SimulatorFactory creates the simulator of a recommender run, see simulator.New.
+type SimulatorFactory func(backend simulator.Backend, vcp kvclapi.ControlPlane, strategy string, parallelism int, profile *schedulerconfig.KubeSchedulerProfile) (simulator.Simulator, error)
+
+// Option configures the recommender created by NewRecommender.
+type Option func(*recommender)
 
-type nodeUtilisationInfo struct {
-	Zone              string              `json:"zone,omitempty"`
-	NodePoolName      string              `json:"node_pool_name,omitempty"`
-	Pods              []string            `json:"pods"`
-	ResourcesConsumed corev1.ResourceList `json:"resources_consumed"`
-	Capacity          corev1.ResourceList `json:"capacity,omitempty"`
+// WithSimulatorFactory makes the recommender create the simulator of every run with newSimulator instead of simulator.New.
+func WithSimulatorFactory(newSimulator SimulatorFactory) Option {
+	return func(r *recommender) {
+		r.newSimulator = newSimulator
+	}
 }
 
-type recommenderRunResult struct {
-	NodeUtilInfos   map[string]nodeUtilisationInfo `json:"node_util_infos,omitempty"`
-	UnscheduledPods []string                       `json:"unscheduled_pods,omitempty"`
+type recommender struct {
+	vcp           kvclapi.ControlPlane
+	backend       simulator.Backend
+	simulator     simulator.Simulator
+	pa            pricing.InstancePricingAccess
+	scorer        scaler.Scorer
+	reporter      scaler.ProgressReporter
+	state         simulationState
+	nodeTemplates map[string]gsc.NodeTemplate
+	appVersion    string
+	logger        *slog.Logger
+	// rng generates the run references and node names of a run, it is seeded with the seed of the request.
+	rng *rand.Rand
+	// workers is the maximum number of candidates which are evaluated concurrently.
+	workers int
+	// schedulerProfile schedules the pods of requests which do not pass their own profile. If nil, the bin-packing
+	// profile of kvcl is used.
+	schedulerProfile *schedulerconfig.KubeSchedulerProfile
+	// newSimulator creates the simulator of a run.
+	newSimulator SimulatorFactory
 }
 
 type podResourceInfo struct {
@@ -85,8 +93,25 @@ type runResult struct {
 	unscheduledPods []*corev1.Pod
 	nodeToPods      map[string][]podResourceInfo
 	nodeCapacity    corev1.ResourceList
-	err             error
-	logs            []string
+	// daemonSetPods are the DaemonSet pods placed on the node of the candidate.
+	daemonSetPods []podResourceInfo
+	// daemonSetOverhead is the sum of the requests of daemonSetPods.
+	daemonSetOverhead corev1.ResourceList
+	err               error
+	logs              []string
+	// order is the position of the candidate within its round.
+	order int
+	// duration is the time taken to evaluate the candidate.
+	duration time.Duration
+}
+
+// candidate is a node pool and zone combination which is evaluated within a round.
+type candidate struct {
+	order          int
+	nodePool       api.NodePool
+	zone           string
+	runRef         lo.Tuple2[string, string]
+	nodeNamePrefix string
 }
 
 func errorRunResult(err error) *runResult {
@@ -105,6 +130,8 @@ type simulationState struct {
 	// eligibleNodePools holds the available node capacity per node pool.
 	eligibleNodePools map[string]api.NodePool
 	priorityClasses   []v1.PriorityClass
+	// daemonSetPods holds an unbound pod per DaemonSet, which is placed on every new node the pod tolerates.
+	daemonSetPods []*corev1.Pod
 }
 
 func (s *simulationState) updateEligibleNodePools(recommendation *api.ScaleUpRecommendation) {
@@ -128,112 +155,146 @@ func (s *simulationState) getUnscheduledPodObjectKeys() []client.ObjectKey {
 	return objKeys
 }
 
-func NewRecommender(vcp kvclapi.ControlPlane, appVersion string, baseLogger *slog.Logger) scaler.Recommender {
-	return &recommender{
-		nc:         vcp.NodeControl(),
-		pc:         vcp.PodControl(),
-		ec:         vcp.EventControl(),
-		client:     vcp.Client(),
-		appVersion: appVersion,
-		logger:     baseLogger,
+// NewRecommender creates a scale-up recommender which simulates the scheduling of pods with the given backend and
+// scheduler profile and evaluates up to workers candidates concurrently. The virtual control plane is only used by the
+// kvcl backend and may be nil otherwise.
+func NewRecommender(vcp kvclapi.ControlPlane, backend simulator.Backend, workers int, schedulerProfile *schedulerconfig.KubeSchedulerProfile, appVersion string, baseLogger *slog.Logger, opts ...Option) scaler.Recommender {
+	r := &recommender{
+		vcp:              vcp,
+		backend:          backend,
+		workers:          workers,
+		schedulerProfile: schedulerProfile,
+		appVersion:       appVersion,
+		logger:           baseLogger,
+		newSimulator:     simulator.New,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
-func (r *recommender) Run(ctx context.Context, scorer scaler.Scorer, simReq api.SimulationRequest) scaler.Result {
+func (r *recommender) Run(ctx context.Context, scorer scaler.Scorer, simReq api.SimulationRequest, reporter scaler.ProgressReporter) scaler.Result {
 	var (
-		recommendations   []api.ScaleUpRecommendation
-		runNumber         int
-		recommenderResult recommenderRunResult
-		scores            []api.RunResultScores
+		recommendations []api.ScaleUpRecommendation
+		runNumber       int
+		scores          []api.RunResultScores
+		partialReason   string
 	)
-	nodeUtilisationInfos := make(map[string]nodeUtilisationInfo)
-	resultLogsDir, err := makeResultsLogDir()
-	if err != nil {
-		return scaler.Result{Err: err}
-	}
-	resultsLogPath := filepath.Join(resultLogsDir, fmt.Sprintf("%s-results.log", simReq.ID))
-	scoresLogPath := "/tmp/scores.log"
-	resultsLogFile, err := os.OpenFile(resultsLogPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	scoresLogFile, err := os.OpenFile(scoresLogPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		r.logger.Error("Failed to open scores log file", "error", err)
-	}
-	defer func() {
-		if err = resultsLogFile.Close(); err != nil {
-			r.logger.Error("Failed to close results log file", "error", err)
-		}
-		if err = scoresLogFile.Close(); err != nil {
-			r.logger.Error("Failed to close scores log file", "error", err)
-		}
-	}()
-	r.resultLogsPath = resultsLogPath
+	nodeUtilisationInfos := make(map[string]api.NodeUtilisationInfo)
 	r.scorer = scorer
-	r.nodeTemplates = simReq.NodeTemplates
+	r.reporter = reporter
+	// nodes are created from the node templates, system components which are not part of the request run on every node.
+	r.nodeTemplates = util.WithSystemOverhead(simReq.NodeTemplates, simReq.SystemOverhead)
+	r.rng = newRand(simReq.Seed)
 	if err := r.initializeSimulationState(simReq); err != nil {
 		return scaler.ErrorResult(err)
 	}
-	if err := r.initializeVirtualCluster(ctx); err != nil {
+	schedulerProfile := r.schedulerProfile
+	if simReq.SchedulerProfile != nil {
+		var err error
+		if schedulerProfile, err = simulator.ConvertSchedulerProfile(*simReq.SchedulerProfile); err != nil {
+			return scaler.ErrorResult(err)
+		}
+	}
+	sim, err := r.newSimulator(r.backend, r.vcp, r.strategyLabel(), r.workers, schedulerProfile)
+	if err != nil {
+		return scaler.ErrorResult(err)
+	}
+	defer sim.Close()
+	r.simulator = sim
+	if err = r.simulator.Initialize(ctx, simulator.BaseState{
+		Nodes:           r.state.existingNodes,
+		Pods:            r.state.scheduledPods,
+		PriorityClasses: r.state.priorityClasses,
+		// volumes restrict the nodes of pods with persistent volume claims and count against the attach limits of nodes.
+		PersistentVolumes:      simReq.PersistentVolumes,
+		PersistentVolumeClaims: simReq.PersistentVolumeClaims,
+		StorageClasses:         simReq.StorageClasses,
+	}); err != nil {
+		if isDeadlineExceeded(ctx) {
+			return scaler.PartialScaleUpResult(nil, r.state.getUnscheduledPodObjectKeys(), "deadline exceeded while initializing the simulator")
+		}
 		return scaler.ErrorResult(err)
 	}
 	for {
+		if err := ctx.Err(); err != nil {
+			if isDeadlineExceeded(ctx) {
+				partialReason = fmt.Sprintf("deadline exceeded after %d completed round(s)", runNumber)
+				break
+			}
+			return scaler.ErrorResult(err)
+		}
 		runNumber++
 		r.logger.Info("Scale-up recommender run started...", "runNumber", runNumber)
 		if len(r.state.unscheduledPods) == 0 {
 			r.logger.Info("All pods are scheduled. Exiting the loop...")
 			break
 		}
+		r.reporter.Report(api.ProgressEvent{
+			Type:          api.RoundStartedEvent,
+			Time:          time.Now(),
+			RunNumber:     runNumber,
+			PodsRemaining: len(r.state.unscheduledPods),
+		})
 		simRunStartTime := time.Now()
 		winnerRunResult := r.runSimulation(ctx, runNumber, &scores)
+		metrics.RoundDuration.WithLabelValues(r.strategyLabel()).Observe(time.Since(simRunStartTime).Seconds())
 		r.logger.Info("Scale-up recommender run completed", "runNumber", runNumber, "duration", time.Since(simRunStartTime).Seconds())
 		if winnerRunResult == nil {
 			r.logger.Info("No winner could be identified. This will happen when no pods could be assigned. No more runs are required, exiting early", "runNumber", runNumber)
 			break
 		}
 		if winnerRunResult.err != nil {
+			if isDeadlineExceeded(ctx) {
+				partialReason = fmt.Sprintf("deadline exceeded during round %d, its results have been discarded", runNumber)
+				break
+			}
 			r.logger.Error("runSimulation failed", "err", winnerRunResult.err)
 			break
 		}
 		recommendation := createScaleUpRecommendationFromResult(*winnerRunResult)
-		if err := r.syncWinningResult(ctx, &recommendation, winnerRunResult); err != nil {
+		// the winner is synced even if the deadline expires meanwhile so that the state stays consistent with the recommendations.
+		if err := r.syncWinningResult(context.WithoutCancel(ctx), &recommendation, winnerRunResult); err != nil {
 			return scaler.ErrorResult(err)
 		}
+		r.reporter.Report(api.ProgressEvent{
+			Type:          api.WinnerChosenEvent,
+			Time:          time.Now(),
+			RunNumber:     runNumber,
+			NodePoolName:  winnerRunResult.nodePoolName,
+			Zone:          winnerRunResult.zone,
+			InstanceType:  winnerRunResult.instanceType,
+			Score:         winnerRunResult.nodeScore,
+			PodsRemaining: len(r.state.unscheduledPods),
+		})
 		nodeUtilisationInfos = appendNodeUtilisationInfo(*winnerRunResult, nodeUtilisationInfos)
-		r.writeWinningResult(winnerRunResult, resultsLogFile)
 		r.logger.Info("For scale-up recommender", "runNumber", runNumber, "winning-score", recommendation)
 		recommendations = append(recommendations, recommendation)
 		//recommendations = appendScaleUpRecommendation(recommendations, recommendation)
 	}
-	recommenderRunResultLogPath := filepath.Join(resultLogsDir, fmt.Sprintf("%s-util-info.json", simReq.ID))
-	recommenderResult = recommenderRunResult{
-		NodeUtilInfos:   nodeUtilisationInfos,
-		UnscheduledPods: util.GetPodNames(r.state.unscheduledPods),
-	}
-	r.writeScores(scores, scoresLogFile)
-	r.writeRecommenderRunResults(recommenderResult, recommenderRunResultLogPath)
-	return scaler.OkScaleUpResult(recommendations, r.state.getUnscheduledPodObjectKeys())
-}
-
-func (r *recommender) writeRecommenderRunResults(recommenderResult recommenderRunResult, resultsLogPath string) {
-	bytes, err := json.Marshal(recommenderResult)
-	if err != nil {
-		r.logger.Error("Failed to marshal recommender run result", "error", err)
+	if err := ctx.Err(); err != nil && !isDeadlineExceeded(ctx) {
+		return scaler.ErrorResult(err)
 	}
-	if err = os.WriteFile(resultsLogPath, bytes, 0644); err != nil {
-		r.logger.Error("Failed to write recommender run result to file", "error", err)
+	metrics.RecommendedNodes.WithLabelValues(r.strategyLabel()).Observe(float64(lo.SumBy(recommendations, func(recommendation api.ScaleUpRecommendation) int32 {
+		return recommendation.IncrementBy
+	})))
+	metrics.UnscheduledPods.WithLabelValues(r.strategyLabel()).Observe(float64(len(r.state.unscheduledPods)))
+	result := scaler.OkScaleUpResult(recommendations, r.state.getUnscheduledPodObjectKeys())
+	if partialReason != "" {
+		r.logger.Warn("Returning partial recommendation", "reason", partialReason, "recommendations", len(recommendations))
+		result = scaler.PartialScaleUpResult(recommendations, r.state.getUnscheduledPodObjectKeys(), partialReason)
 	}
+	result.Ok.Scores = scores
+	result.Ok.NodeUtilisation = nodeUtilisationInfos
+	return result
 }
 
-func (r *recommender) writeWinningResult(result *runResult, file *os.File) {
-	if _, err := file.WriteString(fmt.Sprintf("NodePool: %s, Node: %s, Zone: %s, InstanceType: %s, ScheduledPods: %v\n", result.nodePoolName, result.nodeName, result.zone, result.instanceType, result.nodeToPods)); err != nil {
-		r.logger.Error("Failed to write winning result to file", "error", err)
-	}
+func isDeadlineExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
 }
 
-func (r *recommender) writeScores(scores []api.RunResultScores, file *os.File) {
-	encoder := json.NewEncoder(file)
-	err := encoder.Encode(scores)
-	if err != nil {
-		r.logger.Error("Failed to marshal scores", "error", err)
-	}
+func (r *recommender) strategyLabel() string {
+	return string(r.scorer.Strategy())
 }
 
 func (r *recommender) initializeSimulationState(simReq api.SimulationRequest) error {
@@ -243,6 +304,12 @@ func (r *recommender) initializeSimulationState(simReq api.SimulationRequest) er
 	if err != nil {
 		return err
 	}
+	r.state.daemonSetPods = util.GetDaemonSetPods(pods)
+	// pending DaemonSet pods are bound to existing nodes by the DaemonSet controller, they are neither scheduled nor part
+	// of the base state.
+	pods = lo.Reject(pods, func(pod *corev1.Pod, _ int) bool {
+		return pod.Spec.NodeName == "" && util.IsDaemonSetPod(pod)
+	})
 	r.state.unscheduledPods, r.state.scheduledPods = util.SplitScheduledAndUnscheduledPods(pods)
 	r.state.originalUnscheduledPods = lo.SliceToMap[*corev1.Pod, string, *corev1.Pod](r.state.unscheduledPods, func(pod *corev1.Pod) (string, *corev1.Pod) {
 		return pod.Name, pod
@@ -262,13 +329,6 @@ func (r *recommender) initializeSimulationState(simReq api.SimulationRequest) er
 	return nil
 }
 
-func (r *recommender) computeTotalZonesAcrossNodePools() int {
-	totalZones := 0
-	for _, np := range r.state.eligibleNodePools {
-		totalZones += np.Zones.Len()
-	}
-	return totalZones
-}
 func getPodNamesForNodes(nodeToPods map[string][]podResourceInfo) map[string][]string {
 	nodeToPodNames := make(map[string][]string)
 	for nodeName, pods := range nodeToPods {
@@ -282,8 +342,9 @@ func getPodNamesForNodes(nodeToPods map[string][]podResourceInfo) map[string][]s
 func (r *recommender) runSimulation(ctx context.Context, runNum int, scores *[]api.RunResultScores) *runResult {
 	var results []*runResult
 	scoresForRun := api.RunResultScores{RunNumber: runNum, AppVersion: r.appVersion}
-	resultCh := make(chan *runResult, r.computeTotalZonesAcrossNodePools())
-	r.triggerNodePoolSimulations(ctx, resultCh, runNum)
+	candidates := r.createCandidates()
+	resultCh := make(chan *runResult, len(candidates))
+	go r.triggerNodePoolSimulations(ctx, candidates, resultCh)
 
 	// label, taint, result chan, error chan, close chan
 	var errs error
@@ -292,22 +353,40 @@ func (r *recommender) runSimulation(ctx context.Context, runNum int, scores *[]a
 		if result.err != nil {
 			errs = errors.Join(errs, result.err)
 		} else {
-			if result.HasWinner() {
-				results = append(results, result)
-				npScore := api.NodePoolInstanceScore{
-					Name:           result.nodePoolName,
-					Zone:           result.zone,
-					InstanceType:   result.instanceType,
-					Score:          result.nodeScore,
-					NodeToPodNames: getPodNamesForNodes(result.nodeToPods),
-				}
-				scoresForRun.Scores = append(scoresForRun.Scores, npScore)
-			}
+			r.reporter.Report(api.ProgressEvent{
+				Type:          api.CandidateScoredEvent,
+				Time:          time.Now(),
+				RunNumber:     runNum,
+				NodePoolName:  result.nodePoolName,
+				Zone:          result.zone,
+				InstanceType:  result.instanceType,
+				Score:         result.nodeScore,
+				PodsRemaining: len(result.unscheduledPods),
+			})
+			results = append(results, result)
 		}
 	}
 	if errs != nil {
 		return errorRunResult(errs)
 	}
+	// results arrive in the order in which the candidates complete, they are reported in the order of the candidates.
+	slices.SortFunc(results, func(a, b *runResult) int {
+		return a.order - b.order
+	})
+	for _, result := range results {
+		scoresForRun.Scores = append(scoresForRun.Scores, api.NodePoolInstanceScore{
+			Name:              result.nodePoolName,
+			Zone:              result.zone,
+			InstanceType:      result.instanceType,
+			Score:             result.nodeScore,
+			NodeToPodNames:    getPodNamesForNodes(result.nodeToPods),
+			Duration:          result.duration.String(),
+			DaemonSetOverhead: result.daemonSetOverhead,
+		})
+	}
+	results = lo.Filter(results, func(result *runResult, _ int) bool {
+		return result.HasWinner()
+	})
 	winnerRunResult := getWinningRunResult(results)
 	if winnerRunResult != nil {
 		for i, score := range scoresForRun.Scores {
@@ -322,56 +401,64 @@ func (r *recommender) runSimulation(ctx context.Context, runNum int, scores *[]a
 	return winnerRunResult
 }
 
-func (r *recommender) triggerNodePoolSimulations(ctx context.Context, resultCh chan *runResult, runNum int) {
-	wg := &sync.WaitGroup{}
-	r.logger.Info("Starting simulation runs for nodePools", "NodePools", maps.Keys(r.state.eligibleNodePools))
+// createCandidates returns a candidate per zone of every eligible node pool. Node pools and zones are ordered by name and
+// the run references and node names are drawn in that order so that runs with the same seed are reproducible.
+func (r *recommender) createCandidates() []candidate {
+	nodePoolNames := maps.Keys(r.state.eligibleNodePools)
+	slices.Sort(nodePoolNames)
+	r.logger.Info("Starting simulation runs for nodePools", "NodePools", nodePoolNames)
+	var candidates []candidate
+	for _, nodePoolName := range nodePoolNames {
+		nodePool := r.state.eligibleNodePools[nodePoolName]
+		for _, zone := range sets.List(nodePool.Zones) {
+			candidates = append(candidates, candidate{
+				order:          len(candidates),
+				nodePool:       nodePool,
+				zone:           zone,
+				runRef:         lo.T2(simRunKey, r.randomString(4)),
+				nodeNamePrefix: r.randomString(4),
+			})
+		}
+	}
+	return candidates
+}
 
-	for _, nodePool := range r.state.eligibleNodePools {
+// triggerNodePoolSimulations evaluates the candidates with up to r.workers concurrent workers and closes resultCh once
+// all candidates have been evaluated. The base state of the round, i.e. the existing nodes and the scheduled pods, already
+// lives in the simulator and is shared by all candidates. A candidate only overlays its node and copies of the
+// unscheduled pods, which the simulator drops again once they have been scheduled.
+func (r *recommender) triggerNodePoolSimulations(ctx context.Context, candidates []candidate, resultCh chan *runResult) {
+	candidateCh := make(chan candidate)
+	wg := &sync.WaitGroup{}
+	for range min(r.workers, len(candidates)) {
 		wg.Add(1)
-		runRef := lo.T2(simRunKey, rand.String(4))
-		r.runSimulationForNodePool(ctx, wg, nodePool, resultCh, runRef)
+		go func() {
+			defer wg.Done()
+			for c := range candidateCh {
+				resultCh <- r.runSimulationForCandidate(ctx, c)
+			}
+		}()
+	}
+	for _, c := range candidates {
+		candidateCh <- c
 	}
+	close(candidateCh)
 	wg.Wait()
 	close(resultCh)
 }
 
-func (r *recommender) runSimulationForNodePool(ctx context.Context, wg *sync.WaitGroup, nodePool api.NodePool, resultCh chan *runResult, runRef lo.Tuple2[string, string]) {
-	var (
-		scheduledPods []string
-		err           error
-	)
-	defer wg.Done()
-	defer func() {
-		err = r.cleanUpNodePoolSimRun(ctx, runRef, &scheduledPods)
-		if err != nil {
-			slog.Error("Failed to clean up simulation run", "runRef", runRef.B, "error", err)
-		}
-	}()
-	if nodePool.Name == "edge-b" {
-		r.logger.Info("Running simulation for nodePool", "nodePool", nodePool.Name, "runRef", runRef.B)
-	}
-	// create a copy of all nodes and scheduled pods only
-	if scheduledPods, err = r.setupSimulationRun(ctx, runRef); err != nil {
-		resultCh <- errorRunResult(err)
-		return
-	}
-	for _, zone := range nodePool.Zones.UnsortedList() {
-		runResult := r.runSimForZone(ctx, runRef, nodePool, zone)
-		resultCh <- runResult
-		if runResult.err != nil {
-			return
-		}
-	}
+func (r *recommender) runSimulationForCandidate(ctx context.Context, c candidate) *runResult {
+	startTime := time.Now()
+	result := r.runSimForZone(ctx, c.runRef, c.nodePool, c.zone, c.nodeNamePrefix)
+	result.order = c.order
+	result.duration = time.Since(startTime)
+	metrics.NodePoolSimulationDuration.WithLabelValues(r.strategyLabel()).Observe(result.duration.Seconds())
+	return result
 }
 
-func (r *recommender) runSimForZone(ctx context.Context, runRef lo.Tuple2[string, string], nodePool api.NodePool, zone string) *runResult {
-	var (
-		nodeName            string
-		unscheduledPodNames []string
-		simRunLogs          []string
-	)
+func (r *recommender) runSimForZone(ctx context.Context, runRef lo.Tuple2[string, string], nodePool api.NodePool, zone, nodeNamePrefix string) *runResult {
+	var simRunLogs []string
 	simRunLogs = append(simRunLogs, fmt.Sprintf("Starting simulation run for nodePool: %s, zone: %s, runRef: %s...\n", nodePool.Name, zone, runRef.B))
-	defer r.cleanUpSimRunForZone(ctx, nodePool.Name, runRef.B, &nodeName, &unscheduledPodNames)
 	//foundNodeTemplate, ok := r.nodeTemplates[nodePool.InstanceType]
 	foundNodeTemplate := util.FindNodeTemplate(r.nodeTemplates, nodePool.Name, zone)
 	if foundNodeTemplate == nil {
@@ -380,149 +467,31 @@ func (r *recommender) runSimForZone(ctx context.Context, runRef lo.Tuple2[string
 	//if !ok {
 	//	return errorRunResult(fmt.Errorf("node template not found for instance type %s", nodePool.InstanceType))
 	//}
-	node, err := util.ConstructNodeForSimRun(*foundNodeTemplate, nodePool.Name, zone, runRef)
-	if err != nil {
-		return errorRunResult(err)
-	}
-	nodeName = node.Name
-	if err = kvcl.CreateAndUntaintNode(ctx, r.nc, common.NotReadyTaintKey, node); err != nil {
-		return errorRunResult(err)
-	}
-
-	deployTime := time.Now()
-	//r.logger.Info("Deploying unscheduled pods", "nodePool name", nodePool.Name, "runRef", runRef)
-	unscheduledPods, err := r.createAndDeployUnscheduledPods(ctx, runRef)
-	if err != nil {
-		return errorRunResult(err)
-	}
-	//r.logger.Info("Deployed unscheduled pods", "nodePool name", nodePool.Name, "runRef", runRef)
-	unscheduledPodNames = util.GetPodNames(unscheduledPods)
-	scheduledPodNames, unSchedulePodNames, err := r.ec.GetPodSchedulingEvents(ctx, common.DefaultNamespace, deployTime, unscheduledPods, 10*time.Second)
-	if err != nil {
-		return errorRunResult(err)
-	}
-	simRunLogs = append(simRunLogs, fmt.Sprintf("Received Pod scheduling events for [nodePool: %s, runRef: %s]: scheduledPodNames: %v, unSchedulePodNames: %v\n", nodePool.Name, runRef.B, scheduledPodNames.UnsortedList(), unSchedulePodNames.UnsortedList()))
-	simRunCandidatePods, err := r.pc.GetPodsMatchingPodNames(ctx, common.DefaultNamespace, scheduledPodNames.UnsortedList()...)
+	node := util.ConstructNodeForSimRun(*foundNodeTemplate, nodeNamePrefix, nodePool.Name, zone, runRef)
+	daemonSetPods := r.constructDaemonSetPods(node, runRef)
+	simRunPods, err := r.simulator.Simulate(ctx, node, append(daemonSetPods, r.constructSimRunPods(runRef)...))
 	if err != nil {
 		return errorRunResult(err)
 	}
+	// the DaemonSet pods precede the pending pods and are placed on node as they are.
+	simRunDaemonSetPods, simRunPods := simRunPods[:len(daemonSetPods)], simRunPods[len(daemonSetPods):]
+	simRunCandidatePods := lo.Filter(simRunPods, func(pod *corev1.Pod, _ int) bool {
+		return pod.Spec.NodeName != ""
+	})
+	simRunLogs = append(simRunLogs, fmt.Sprintf("Received Pod scheduling results for [nodePool: %s, runRef: %s]: scheduledPodNames: %v\n", nodePool.Name, runRef.B, util.GetPodNames(simRunCandidatePods)))
 	ns := r.scorer.Compute(node, simRunCandidatePods)
-	simRunResult := r.computeRunResult(nodePool.Name, nodePool.InstanceType, zone, node, ns, getUpdatedPods(unscheduledPods, simRunCandidatePods))
+	simRunResult := r.computeRunResult(nodePool.Name, nodePool.InstanceType, zone, node, ns, simRunPods)
+	simRunResult.daemonSetPods = lo.Map(simRunDaemonSetPods, func(pod *corev1.Pod, _ int) podResourceInfo {
+		return podResourceInfo{name: pod.Name, request: cumulatePodRequests(pod)}
+	})
+	simRunResult.daemonSetOverhead = sumPodRequests(simRunResult.daemonSetPods)
 	simRunLogs = append(simRunLogs, fmt.Sprintf("Simulation run result for [nodePool: %s, runRef: %s]: {score: %f, unscheduledPods: %v}\n", nodePool.Name, runRef.B, simRunResult.nodeScore, util.GetPodNames(simRunResult.unscheduledPods)))
 	simRunResult.logs = simRunLogs
 	return simRunResult
 }
 
-func (r *recommender) cleanUpSimRunForZone(ctx context.Context, nodePoolName, runRefVal string, nodeName *string, podNames *[]string) {
-	r.logger.Info("cleaning up sim run", "nodePoolName", nodePoolName, "runRef", runRefVal)
-	if nodeName != nil {
-		//r.logger.Info("Deleting node", "nodePoolName", nodePoolName, "runRef", runRefVal, "nodeName", *nodeName)
-		if err := r.nc.DeleteNodes(ctx, *nodeName); err != nil {
-			r.logger.Error("Failed to delete node", "nodePoolName", nodePoolName, "runRef", runRefVal, "nodeName", *nodeName, "error", err)
-		}
-	}
-
-	if podNames != nil && len(*podNames) > 0 {
-		//r.logger.Info("Deleting pods", "nodePoolName", nodePoolName, "runRef", runRefVal, "podNames", *podNames)
-		if err := r.pc.DeletePodsMatchingNames(ctx, common.DefaultNamespace, *podNames...); err != nil {
-			r.logger.Error("Failed to delete pods", "nodePoolName", nodePoolName, "runRef", runRefVal, "podNames", *podNames, "error", err)
-		}
-	}
-}
-
-func getUpdatedPods(original []*corev1.Pod, scheduled []*corev1.Pod) []*corev1.Pod {
-	updatedPods := make([]*corev1.Pod, 0, len(original))
-	for _, op := range original {
-		matchingScheduledPod, found := lo.Find(scheduled, func(p *corev1.Pod) bool {
-			return p.Name == op.Name
-		})
-		if found {
-			updatedPods = append(updatedPods, matchingScheduledPod)
-		} else {
-			updatedPods = append(updatedPods, op)
-		}
-	}
-	return updatedPods
-}
-
-func (r *recommender) cleanUpNodePoolSimRun(ctx context.Context, runRef lo.Tuple2[string, string], podNames *[]string) error {
-	labels := util.AsMap(runRef)
-	var errs error
-	errs = errors.Join(errs, r.pc.DeletePodsMatchingNames(ctx, common.DefaultNamespace, *podNames...))
-	errs = errors.Join(errs, r.nc.DeleteNodesMatchingLabels(ctx, labels))
-	return errs
-}
-
-func (r *recommender) setupSimulationRun(ctx context.Context, runRef lo.Tuple2[string, string]) ([]string, error) {
-	// create copy of all nodes (barring existing nodes)
-	clonedNodes := make([]*corev1.Node, 0, len(r.state.existingNodes))
-	for _, node := range r.state.existingNodes {
-		nodeCopy := node.DeepCopy()
-		nodeCopy.Name = fromOriginalResourceName(nodeCopy.Name, runRef.B)
-		nodeCopy.Labels[runRef.A] = runRef.B
-		nodeCopy.Labels["kubernetes.io/hostname"] = nodeCopy.Name
-		nodeCopy.ObjectMeta.UID = ""
-		nodeCopy.ObjectMeta.ResourceVersion = ""
-		nodeCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
-		nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, corev1.Taint{
-			Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule,
-		})
-		clonedNodes = append(clonedNodes, nodeCopy)
-	}
-	if err := kvcl.CreateAndUntaintNode(ctx, r.nc, common.NotReadyTaintKey, clonedNodes...); err != nil {
-		return nil, err
-	}
-
-	// create copy of all scheduled pods
-	clonedScheduledPods := make([]*corev1.Pod, 0, len(r.state.scheduledPods))
-	for _, pod := range r.state.scheduledPods {
-		podCopy := pod.DeepCopy()
-		podCopy.Name = fromOriginalResourceName(podCopy.Name, runRef.B)
-		if podCopy.Labels == nil {
-			podCopy.Labels = make(map[string]string)
-		}
-		podCopy.Labels[runRef.A] = runRef.B
-		podCopy.ObjectMeta.UID = ""
-		podCopy.ObjectMeta.ResourceVersion = ""
-		podCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
-		podCopy.Spec.Tolerations = append(podCopy.Spec.Tolerations, corev1.Toleration{
-			Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule, Operator: corev1.TolerationOpEqual,
-		})
-		if len(podCopy.Spec.TopologySpreadConstraints) > 0 {
-			updatedTSC := make([]corev1.TopologySpreadConstraint, 0, len(podCopy.Spec.TopologySpreadConstraints))
-			for _, tsc := range podCopy.Spec.TopologySpreadConstraints {
-				if tsc.LabelSelector == nil {
-					tsc.LabelSelector = &metav1.LabelSelector{
-						MatchLabels:      nil,
-						MatchExpressions: nil,
-					}
-				}
-				if tsc.LabelSelector.MatchLabels == nil {
-					tsc.LabelSelector.MatchLabels = make(map[string]string)
-				}
-				tsc.LabelSelector.MatchLabels[runRef.A] = runRef.B
-				updatedTSC = append(updatedTSC, tsc)
-			}
-			podCopy.Spec.TopologySpreadConstraints = updatedTSC
-		}
-		podCopy.Spec.NodeName = fromOriginalResourceName(podCopy.Spec.NodeName, runRef.B)
-		clonedScheduledPods = append(clonedScheduledPods, podCopy)
-	}
-	if err := r.pc.CreatePods(ctx, clonedScheduledPods...); err != nil {
-		return nil, err
-	}
-	return util.GetPodNames(clonedScheduledPods), nil
-}
-
-func (r *recommender) resetNodePoolSimRun(ctx context.Context, nodeName string, runRef lo.Tuple2[string, string]) error {
-	// remove the node with the nodeName
-	if err := r.nc.DeleteNodes(ctx, nodeName); err != nil {
-		return err
-	}
-	return r.pc.DeletePodsMatchingLabels(ctx, common.DefaultNamespace, util.AsMap(runRef))
-}
-
-func (r *recommender) createAndDeployUnscheduledPods(ctx context.Context, runRef lo.Tuple2[string, string]) ([]*corev1.Pod, error) {
+// constructSimRunPods returns copies of the unscheduled pods for the candidate identified by runRef.
+func (r *recommender) constructSimRunPods(runRef lo.Tuple2[string, string]) []*corev1.Pod {
 	unscheduledPods := make([]*corev1.Pod, 0, len(r.state.unscheduledPods))
 	for _, pod := range r.state.unscheduledPods {
 		podCopy := pod.DeepCopy()
@@ -539,32 +508,55 @@ func (r *recommender) createAndDeployUnscheduledPods(ctx context.Context, runRef
 		}
 		tolerations = append(tolerations, podCopy.Spec.Tolerations...)
 		podCopy.Spec.Tolerations = tolerations
-		if len(podCopy.Spec.TopologySpreadConstraints) > 0 {
-			updatedTSC := make([]corev1.TopologySpreadConstraint, 0, len(podCopy.Spec.TopologySpreadConstraints))
-			for _, tsc := range podCopy.Spec.TopologySpreadConstraints {
-				if tsc.LabelSelector == nil {
-					tsc.LabelSelector = &metav1.LabelSelector{
-						MatchLabels:      nil,
-						MatchExpressions: nil,
-					}
-				}
-				if tsc.LabelSelector.MatchLabels == nil {
-					tsc.LabelSelector.MatchLabels = make(map[string]string)
-				}
-				tsc.LabelSelector.MatchLabels[runRef.A] = runRef.B
-				updatedTSC = append(updatedTSC, tsc)
+		// topology spread constraints are kept as they are so that they take the scheduled pods of the base state into
+		// account. Candidates are evaluated concurrently, the simulator keeps their pods apart: the in-process backend gives
+		// every worker its own overlay of the base state and kvcl restricts the selectors of the pods to the lane of the
+		// worker, whose nodes and pods carry the lane label.
+		podCopy.Spec.SchedulerName = common.BinPackingSchedulerName
+		// a candidate must not evict pods of the base state, which is also not simulated by the in-process backend.
+		podCopy.Spec.PreemptionPolicy = ptr.To(corev1.PreemptNever)
+		unscheduledPods = append(unscheduledPods, podCopy)
+	}
+	return unscheduledPods
+}
+
+// constructDaemonSetPods returns a copy of every DaemonSet pod which the DaemonSet controller would create on node, bound to
+// node. The pods are named after the DaemonSet pod and node, runRef is empty for the nodes of winning candidates.
+func (r *recommender) constructDaemonSetPods(node *corev1.Node, runRef lo.Tuple2[string, string]) []*corev1.Pod {
+	var daemonSetPods []*corev1.Pod
+	for _, pod := range r.state.daemonSetPods {
+		podCopy := pod.DeepCopy()
+		if runRef.A != "" {
+			podCopy.Spec.Tolerations = append(podCopy.Spec.Tolerations, corev1.Toleration{Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule, Operator: corev1.TolerationOpEqual})
+		}
+		if !util.ShouldRunDaemonSetPod(podCopy, node) {
+			continue
+		}
+		podCopy.Name = toOriginalResourceName(podCopy.Name) + "-" + toOriginalResourceName(node.Name)
+		if runRef.A != "" {
+			podCopy.Name = fromOriginalResourceName(podCopy.Name, runRef.B)
+			podCopy.Labels = maps.Clone(podCopy.Labels)
+			if podCopy.Labels == nil {
+				podCopy.Labels = make(map[string]string)
 			}
-			podCopy.Spec.TopologySpreadConstraints = updatedTSC
+			podCopy.Labels[runRef.A] = runRef.B
 		}
+		podCopy.Spec.NodeName = node.Name
 		podCopy.Spec.SchedulerName = common.BinPackingSchedulerName
-		unscheduledPods = append(unscheduledPods, podCopy)
+		podCopy.ObjectMeta.UID = ""
+		podCopy.ObjectMeta.ResourceVersion = ""
+		podCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
+		daemonSetPods = append(daemonSetPods, podCopy)
 	}
-	return unscheduledPods, r.pc.CreatePods(ctx, unscheduledPods...)
+	return daemonSetPods
 }
 
 func (r *recommender) computeRunResult(nodePoolName, instanceType, zone string, node *corev1.Node, nodeScore float64, pods []*corev1.Pod) *runResult {
 	if nodeScore == 0.0 {
 		return &runResult{
+			nodePoolName:    nodePoolName,
+			zone:            zone,
+			instanceType:    instanceType,
 			unscheduledPods: pods,
 		}
 	}
@@ -593,6 +585,21 @@ func (r *recommender) computeRunResult(nodePoolName, instanceType, zone string,
 	}
 }
 
+func sumPodRequests(podResInfos []podResourceInfo) corev1.ResourceList {
+	if len(podResInfos) == 0 {
+		return nil
+	}
+	sumRequests := make(corev1.ResourceList)
+	for _, podResInfo := range podResInfos {
+		for name, quantity := range podResInfo.request {
+			sumQuantity := sumRequests[name]
+			sumQuantity.Add(quantity)
+			sumRequests[name] = sumQuantity
+		}
+	}
+	return sumRequests
+}
+
 func cumulatePodRequests(pod *corev1.Pod) corev1.ResourceList {
 	sumRequests := make(corev1.ResourceList)
 	for _, container := range pod.Spec.Containers {
@@ -614,23 +621,20 @@ func (r *recommender) syncWinningResult(ctx context.Context, recommendation *api
 	defer func() {
 		r.logger.Info("syncWinningResult for nodePool completed", "nodePool", recommendation.NodePoolName, "duration", time.Since(startTime).Seconds())
 	}()
-	scheduledPodNames, err := r.syncVirtualClusterWithWinningResult(ctx, winningRunResult)
+	winnerNode, daemonSetPods, scheduledPods, err := r.constructWinningNodeAndPods(winningRunResult)
 	if err != nil {
 		return err
 	}
-	return r.syncRecommenderStateWithWinningResult(ctx, recommendation, winningRunResult.nodeName, scheduledPodNames)
-}
-
-func (r *recommender) syncRecommenderStateWithWinningResult(ctx context.Context, recommendation *api.ScaleUpRecommendation, winningNodeName string, scheduledPodNames []string) error {
-	winnerNode, err := r.nc.GetNode(ctx, types.NamespacedName{Name: winningNodeName, Namespace: common.DefaultNamespace})
-	if err != nil {
+	scheduledPods = append(daemonSetPods, scheduledPods...)
+	if err = r.simulator.Commit(ctx, winnerNode, scheduledPods); err != nil {
 		return err
 	}
+	r.syncRecommenderStateWithWinningResult(recommendation, winnerNode, scheduledPods)
+	return nil
+}
+
+func (r *recommender) syncRecommenderStateWithWinningResult(recommendation *api.ScaleUpRecommendation, winnerNode *corev1.Node, scheduledPods []*corev1.Pod) {
 	r.state.existingNodes = append(r.state.existingNodes, winnerNode)
-	scheduledPods, err := r.pc.GetPodsMatchingPodNames(ctx, common.DefaultNamespace, scheduledPodNames...)
-	if err != nil {
-		return err
-	}
 	for _, pod := range scheduledPods {
 		r.state.scheduledPods = append(r.state.scheduledPods, pod)
 		r.state.unscheduledPods = slices.DeleteFunc(r.state.unscheduledPods, func(p *corev1.Pod) bool {
@@ -638,28 +642,27 @@ func (r *recommender) syncRecommenderStateWithWinningResult(ctx context.Context,
 		})
 	}
 	r.state.updateEligibleNodePools(recommendation)
-	return nil
 }
 
-func (r *recommender) syncVirtualClusterWithWinningResult(ctx context.Context, winningRunResult *runResult) ([]string, error) {
+// constructWinningNodeAndPods returns the node of the winning candidate, the DaemonSet pods on it and the original pods
+// bound to it.
+func (r *recommender) constructWinningNodeAndPods(winningRunResult *runResult) (*corev1.Node, []*corev1.Pod, []*corev1.Pod, error) {
 	nodeTemplate := util.FindNodeTemplate(r.nodeTemplates, winningRunResult.nodePoolName, winningRunResult.zone)
 	if nodeTemplate == nil {
-		return nil, fmt.Errorf("node template not found for instance type %s", winningRunResult.instanceType)
+		return nil, nil, nil, fmt.Errorf("node template not found for instance type %s", winningRunResult.instanceType)
 	}
 	node, err := util.ConstructNodeFromNodeTemplate(*nodeTemplate, winningRunResult.zone, winningRunResult.nodeName)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	var originalPods []*corev1.Pod
 	var scheduledPods []*corev1.Pod
 	for nodeName, simPodResInfos := range winningRunResult.nodeToPods {
 		for _, simPodResInfo := range simPodResInfos {
 			podName := toOriginalResourceName(simPodResInfo.name)
 			pod, ok := r.state.originalUnscheduledPods[podName]
 			if !ok {
-				return nil, fmt.Errorf("unexpected error, pod: %s not found in the original pods collection", podName)
+				return nil, nil, nil, fmt.Errorf("unexpected error, pod: %s not found in the original pods collection", podName)
 			}
-			originalPods = append(originalPods, pod)
 			podCopy := pod.DeepCopy()
 			podCopy.Spec.NodeName = toOriginalResourceName(nodeName)
 			podCopy.ObjectMeta.ResourceVersion = ""
@@ -667,30 +670,8 @@ func (r *recommender) syncVirtualClusterWithWinningResult(ctx context.Context, w
 			scheduledPods = append(scheduledPods, podCopy)
 		}
 	}
-	if err = r.pc.CreatePods(ctx, scheduledPods...); err != nil {
-		return nil, err
-	}
-	if err = kvcl.CreateAndUntaintNode(ctx, r.nc, common.NotReadyTaintKey, node); err != nil {
-		return nil, err
-	}
-	return util.GetPodNames(scheduledPods), nil
-}
-
-func (r *recommender) initializeVirtualCluster(ctx context.Context) error {
-	if r.state.existingNodes != nil {
-		if err := util.CreateAndUntaintNodes(ctx, r.client, r.state.existingNodes); err != nil {
-			return fmt.Errorf("failed to initialize virtual cluster with existing nodes: %w", err)
-		}
-	}
-	for _, pc := range r.state.priorityClasses {
-		if err := r.client.Create(ctx, &pc); err != nil {
-			return fmt.Errorf("failed to initialize virtual cluster with priority class: %w", err)
-		}
-	}
-	if r.state.scheduledPods != nil {
-		if err := r.pc.CreatePods(ctx, r.state.scheduledPods...); err != nil {
-			return fmt.Errorf("failed to initialize virtual cluster with scheduled pods: %w", err)
-		}
-	}
-	return nil
+	// DaemonSet pods are not part of the recommendation, they are only kept in the state so that later rounds see the
+	// remaining capacity of the node.
+	daemonSetPods := r.constructDaemonSetPods(node, lo.Tuple2[string, string]{})
+	return node, daemonSetPods, scheduledPods, nil
 }
@@ -0,0 +1,287 @@
+package scaleup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	kvclapi "github.com/unmarshall/kvcl/api"
+	kvcl "github.com/unmarshall/kvcl/pkg/control"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+// TestBackendsRecommendTheSame runs every scenario of client/assets with the in-process and the kvcl backend and expects
+// the same scale-ups and unscheduled pods, as the kvcl backend is the reference of the in-process backend. The names of
+// the recommended nodes are not compared. It requires the binaries of the virtual cluster, see BenchmarkRecommender.
+func TestBackendsRecommendTheSame(t *testing.T) {
+	vcp := startVirtualCluster(t)
+	logger := discardLogger(t)
+	for _, s := range loadTestScenarios(t) {
+		t.Run(s.name, func(t *testing.T) {
+			inProcess := runRecommender(t, NewRecommender(nil, simulator.InProcessBackend, 1, nil, "test", logger), s)
+			resetVirtualCluster(t, vcp)
+			reference := runRecommender(t, NewRecommender(vcp, simulator.KVCLBackend, 1, nil, "test", logger), s)
+			if got, want := scaleUpsWithoutNodeNames(inProcess), scaleUpsWithoutNodeNames(reference); !reflect.DeepEqual(got, want) {
+				t.Errorf("in-process backend recommends %+v, kvcl backend recommends %+v", got, want)
+			}
+			if got, want := unscheduledPodNames(inProcess), unscheduledPodNames(reference); !slices.Equal(got, want) {
+				t.Errorf("in-process backend leaves %v unscheduled, kvcl backend leaves %v unscheduled", got, want)
+			}
+		})
+	}
+}
+
+// BenchmarkBackends runs the recommender with the in-process and the kvcl backend over the scenarios of client/assets.
+// The kvcl benchmarks are skipped unless BINARY_ASSETS_DIR is set, see BenchmarkRecommender.
+func BenchmarkBackends(b *testing.B) {
+	logger := discardLogger(b)
+	for _, s := range loadTestScenarios(b) {
+		b.Run(s.name+"/"+string(simulator.InProcessBackend), func(b *testing.B) {
+			r := NewRecommender(nil, simulator.InProcessBackend, 1, nil, "benchmark", logger)
+			for range b.N {
+				runRecommender(b, r, s)
+			}
+		})
+		b.Run(s.name+"/"+string(simulator.KVCLBackend), func(b *testing.B) {
+			vcp := startVirtualCluster(b)
+			r := NewRecommender(vcp, simulator.KVCLBackend, 1, nil, "benchmark", logger)
+			for range b.N {
+				b.StopTimer()
+				resetVirtualCluster(b, vcp)
+				b.StartTimer()
+				runRecommender(b, r, s)
+			}
+		})
+	}
+}
+
+// runRecommender runs r for the scenario. Some scenarios, like s4a whose node templates are too small for the unscheduled
+// pods, recommend no scale-up.
+func runRecommender(tb testing.TB, r scaler.Recommender, s testScenario) scaler.OkResult {
+	tb.Helper()
+	result := r.Run(context.Background(), s.scorer, s.request, scaler.NoopProgressReporter{})
+	if result.Err != nil {
+		tb.Fatal(result.Err)
+	}
+	return result.Ok
+}
+
+func scaleUpsWithoutNodeNames(result scaler.OkResult) []api.ScaleUpRecommendation {
+	return lo.Map(result.Recommendation.ScaleUp, func(scaleUp api.ScaleUpRecommendation, _ int) api.ScaleUpRecommendation {
+		scaleUp.NodeNames = nil
+		return scaleUp
+	})
+}
+
+func unscheduledPodNames(result scaler.OkResult) []string {
+	names := lo.Map(result.UnscheduledPods, func(key client.ObjectKey, _ int) string {
+		return key.String()
+	})
+	slices.Sort(names)
+	return names
+}
+
+// BenchmarkRecommender runs the recommender with the kvcl backend over the scenarios of client/assets. The
+// shared-base-state variant evaluates the candidates against the base state of the virtual cluster, the per-pool-clone
+// variant clones all existing nodes and scheduled pods for every node pool as the recommender did before. Candidates are
+// evaluated one at a time in both variants, like the node pools were before. It requires the binaries of the virtual
+// cluster:
+//
+//	BINARY_ASSETS_DIR=... go test ./internal/scaler/scaleup -run '^$' -bench Recommender -benchtime 5x
+func BenchmarkRecommender(b *testing.B) {
+	vcp := startVirtualCluster(b)
+	logger := discardLogger(b)
+	variants := []struct {
+		name         string
+		newSimulator SimulatorFactory
+	}{
+		{name: "shared-base-state", newSimulator: simulator.New},
+		{name: "per-pool-clone", newSimulator: newPoolCloningSimulator},
+	}
+	for _, s := range loadTestScenarios(b) {
+		for _, variant := range variants {
+			b.Run(s.name+"/"+variant.name, func(b *testing.B) {
+				r := NewRecommender(vcp, simulator.KVCLBackend, 1, nil, "benchmark", logger, WithSimulatorFactory(variant.newSimulator))
+				for range b.N {
+					b.StopTimer()
+					resetVirtualCluster(b, vcp)
+					b.StartTimer()
+					runRecommender(b, r, s)
+				}
+			})
+		}
+	}
+}
+
+// poolCloningSimulator is the kvcl simulation of the recommender before the base state was shared by all candidates.
+// The base state is created in the virtual cluster once, but every candidate works on its own clone of all existing nodes
+// and scheduled pods. The clones are tainted with the run reference of the candidate and deleted again once the candidate
+// has been evaluated. The recommender draws a run reference per node pool and zone, so pools with more than one zone are
+// cloned once per zone instead of once per pool.
+type poolCloningSimulator struct {
+	nc    kvclapi.NodeControl
+	pc    kvclapi.PodControl
+	ec    kvclapi.EventControl
+	vcp   kvclapi.ControlPlane
+	state simulator.BaseState
+}
+
+func newPoolCloningSimulator(backend simulator.Backend, vcp kvclapi.ControlPlane, _ string, parallelism int, _ *schedulerconfig.KubeSchedulerProfile) (simulator.Simulator, error) {
+	if backend != simulator.KVCLBackend || parallelism != 1 {
+		return nil, fmt.Errorf("the per-pool clone only evaluates one candidate at a time with the kvcl backend")
+	}
+	return &poolCloningSimulator{nc: vcp.NodeControl(), pc: vcp.PodControl(), ec: vcp.EventControl(), vcp: vcp}, nil
+}
+
+func (s *poolCloningSimulator) Initialize(ctx context.Context, state simulator.BaseState) error {
+	s.state = state
+	if err := util.CreateAndUntaintNodes(ctx, s.vcp.Client(), state.Nodes); err != nil {
+		return err
+	}
+	for _, pc := range state.PriorityClasses {
+		if err := s.vcp.Client().Create(ctx, &pc); err != nil {
+			return err
+		}
+	}
+	return s.pc.CreatePods(ctx, state.Pods...)
+}
+
+func (s *poolCloningSimulator) Simulate(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*corev1.Pod, error) {
+	runRef := lo.T2(simRunKey, node.Labels[simRunKey])
+	var clonedPodNames, podNames []string
+	defer func() {
+		cleanUpCtx := context.WithoutCancel(ctx)
+		errs := errors.Join(
+			s.nc.DeleteNodes(cleanUpCtx, node.Name),
+			s.pc.DeletePodsMatchingNames(cleanUpCtx, common.DefaultNamespace, podNames...),
+			s.pc.DeletePodsMatchingNames(cleanUpCtx, common.DefaultNamespace, clonedPodNames...),
+			s.nc.DeleteNodesMatchingLabels(cleanUpCtx, util.AsMap(runRef)),
+		)
+		if errs != nil {
+			panic(errs)
+		}
+	}()
+	var err error
+	if clonedPodNames, err = s.cloneBaseState(ctx, runRef); err != nil {
+		return nil, err
+	}
+	if err = kvcl.CreateAndUntaintNode(ctx, s.nc, common.NotReadyTaintKey, node); err != nil {
+		return nil, err
+	}
+	runPods := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		runPods = append(runPods, withRunScopedTopologySpread(pod, runRef))
+	}
+	deployTime := time.Now()
+	if err = s.pc.CreatePods(ctx, runPods...); err != nil {
+		return nil, err
+	}
+	podNames = util.GetPodNames(runPods)
+	podsToSchedule := lo.Filter(runPods, func(pod *corev1.Pod, _ int) bool {
+		return pod.Spec.NodeName == ""
+	})
+	scheduledPodNames, _, err := s.ec.GetPodSchedulingEvents(ctx, common.DefaultNamespace, deployTime, podsToSchedule, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	scheduledPods, err := s.pc.GetPodsMatchingPodNames(ctx, common.DefaultNamespace, scheduledPodNames.UnsortedList()...)
+	if err != nil {
+		return nil, err
+	}
+	scheduledByName := lo.SliceToMap(scheduledPods, func(pod *corev1.Pod) (string, *corev1.Pod) {
+		return pod.Name, pod
+	})
+	return lo.Map(pods, func(pod *corev1.Pod, _ int) *corev1.Pod {
+		if scheduled, ok := scheduledByName[pod.Name]; ok {
+			return scheduled
+		}
+		return pod
+	}), nil
+}
+
+// cloneBaseState clones the existing nodes and scheduled pods for the run, see setupSimulationRun before the base state
+// was shared.
+func (s *poolCloningSimulator) cloneBaseState(ctx context.Context, runRef lo.Tuple2[string, string]) ([]string, error) {
+	clonedNodes := make([]*corev1.Node, 0, len(s.state.Nodes))
+	for _, node := range s.state.Nodes {
+		nodeCopy := node.DeepCopy()
+		nodeCopy.Name = fromOriginalResourceName(nodeCopy.Name, runRef.B)
+		if nodeCopy.Labels == nil {
+			nodeCopy.Labels = make(map[string]string)
+		}
+		nodeCopy.Labels[runRef.A] = runRef.B
+		nodeCopy.Labels["kubernetes.io/hostname"] = nodeCopy.Name
+		nodeCopy.ObjectMeta.UID = ""
+		nodeCopy.ObjectMeta.ResourceVersion = ""
+		nodeCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
+		nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, corev1.Taint{
+			Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule,
+		})
+		clonedNodes = append(clonedNodes, nodeCopy)
+	}
+	if err := kvcl.CreateAndUntaintNode(ctx, s.nc, common.NotReadyTaintKey, clonedNodes...); err != nil {
+		return nil, err
+	}
+	clonedPods := make([]*corev1.Pod, 0, len(s.state.Pods))
+	for _, pod := range s.state.Pods {
+		podCopy := withRunScopedTopologySpread(pod, runRef)
+		podCopy.Name = fromOriginalResourceName(podCopy.Name, runRef.B)
+		podCopy.Labels[runRef.A] = runRef.B
+		podCopy.Spec.Tolerations = append(podCopy.Spec.Tolerations, corev1.Toleration{
+			Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule, Operator: corev1.TolerationOpEqual,
+		})
+		podCopy.Spec.NodeName = fromOriginalResourceName(podCopy.Spec.NodeName, runRef.B)
+		clonedPods = append(clonedPods, podCopy)
+	}
+	if err := s.pc.CreatePods(ctx, clonedPods...); err != nil {
+		return nil, err
+	}
+	return util.GetPodNames(clonedPods), nil
+}
+
+// withRunScopedTopologySpread returns a copy of pod whose topology spread constraints only count the pods of the run.
+func withRunScopedTopologySpread(pod *corev1.Pod, runRef lo.Tuple2[string, string]) *corev1.Pod {
+	podCopy := pod.DeepCopy()
+	podCopy.ObjectMeta.UID = ""
+	podCopy.ObjectMeta.ResourceVersion = ""
+	podCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
+	if podCopy.Labels == nil {
+		podCopy.Labels = make(map[string]string)
+	}
+	for i := range podCopy.Spec.TopologySpreadConstraints {
+		tsc := &podCopy.Spec.TopologySpreadConstraints[i]
+		if tsc.LabelSelector == nil {
+			tsc.LabelSelector = &metav1.LabelSelector{}
+		}
+		if tsc.LabelSelector.MatchLabels == nil {
+			tsc.LabelSelector.MatchLabels = make(map[string]string)
+		}
+		tsc.LabelSelector.MatchLabels[runRef.A] = runRef.B
+	}
+	return podCopy
+}
+
+func (s *poolCloningSimulator) Commit(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) error {
+	s.state.Nodes = append(s.state.Nodes, node)
+	s.state.Pods = append(s.state.Pods, pods...)
+	if err := s.pc.CreatePods(ctx, pods...); err != nil {
+		return err
+	}
+	return kvcl.CreateAndUntaintNode(ctx, s.nc, common.NotReadyTaintKey, node)
+}
+
+func (s *poolCloningSimulator) Close() {}
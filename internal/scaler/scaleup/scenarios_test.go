@@ -0,0 +1,175 @@
+package scaleup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	gsc "github.com/elankath/gardener-scaling-common"
+	kvclapi "github.com/unmarshall/kvcl/api"
+	kvcl "github.com/unmarshall/kvcl/pkg/control"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"unmarshall/scaling-recommender/api"
+	clientutil "unmarshall/scaling-recommender/client/util"
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/pricing"
+	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/scaler/scorer/costonly"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+// scenariosDir holds the scenario files and scaling history reports shipped with the client.
+const scenariosDir = "../../../client/assets"
+
+// testScenario is a simulation request of client/assets together with the scorer of the provider it was recorded on.
+type testScenario struct {
+	name    string
+	request api.SimulationRequest
+	scorer  scaler.Scorer
+}
+
+// loadTestScenarios loads the scenarios of client/assets and completes their node templates the way the handler does: a
+// template per node pool and zone is derived from the templates keyed on the instance type or synthesised from the
+// pricing catalog of the provider that lists all instance types of the scenario. Scenarios which cannot be loaded are
+// skipped.
+func loadTestScenarios(tb testing.TB) []testScenario {
+	tb.Helper()
+	batchScenarios, err := clientutil.LoadBatchScenarios(scenariosDir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var providers []pricing.InstancePricingAccess
+	for _, provider := range []string{"aws", "gcp"} {
+		pa, err := pricing.NewInstancePricingAccess(provider)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		providers = append(providers, pa)
+	}
+	var scenarios []testScenario
+	for _, bs := range batchScenarios {
+		if bs.Err != nil {
+			tb.Logf("skipping scenario %s: %v", bs.Name, bs.Err)
+			continue
+		}
+		request := *bs.Request
+		pa := findPricingAccess(providers, request.NodePools)
+		if pa == nil {
+			tb.Logf("skipping scenario %s: no pricing catalog lists all of its instance types", bs.Name)
+			continue
+		}
+		if err = completeNodeTemplates(&request, pa); err != nil {
+			tb.Fatalf("scenario %s: %v", bs.Name, err)
+		}
+		scenarios = append(scenarios, testScenario{name: bs.Name, request: request, scorer: costonly.NewScorer(pa)})
+	}
+	if len(scenarios) < 2 {
+		tb.Fatalf("expected at least two scenarios in %s, found %d", scenariosDir, len(scenarios))
+	}
+	return scenarios
+}
+
+func findPricingAccess(providers []pricing.InstancePricingAccess, nodePools []api.NodePool) pricing.InstancePricingAccess {
+	for _, pa := range providers {
+		found := true
+		for _, np := range nodePools {
+			if _, ok := pa.GetInstancePricing(np.InstanceType); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return pa
+		}
+	}
+	return nil
+}
+
+func completeNodeTemplates(request *api.SimulationRequest, pa pricing.InstancePricingAccess) error {
+	if request.NodeTemplates == nil {
+		request.NodeTemplates = make(map[string]gsc.NodeTemplate)
+	}
+	util.AddPoolNodeTemplates(request.NodeTemplates, request.NodePools)
+	var region string
+	for _, nt := range request.NodeTemplates {
+		region = nt.Region
+	}
+	for _, np := range request.NodePools {
+		instancePricing, _ := pa.GetInstancePricing(np.InstanceType)
+		for _, zone := range sets.List(np.Zones) {
+			if util.FindNodeTemplate(request.NodeTemplates, np.Name, zone) != nil {
+				continue
+			}
+			nt, err := util.SynthesizeNodeTemplate(instancePricing, np.Name, zone, region, nil, nil, api.NodeReservation{})
+			if err != nil {
+				return fmt.Errorf("cannot synthesise node template for pool %q in zone %q: %w", np.Name, zone, err)
+			}
+			request.NodeTemplates[nt.Name] = nt
+		}
+	}
+	return nil
+}
+
+// discardLogger is the logger of recommenders in tests, the recommender also logs with the default logger.
+func discardLogger(tb testing.TB) *slog.Logger {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	tb.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	return logger
+}
+
+var (
+	virtualClusterOnce sync.Once
+	virtualCluster     kvclapi.ControlPlane
+	virtualClusterErr  error
+)
+
+// startVirtualCluster returns the embedded virtual cluster of the kvcl backend, which is started once for all tests of
+// the package. Tests are skipped if BINARY_ASSETS_DIR does not point to the kube-apiserver and etcd binaries.
+func startVirtualCluster(tb testing.TB) kvclapi.ControlPlane {
+	tb.Helper()
+	binaryAssetsDir := os.Getenv("BINARY_ASSETS_DIR")
+	if binaryAssetsDir == "" {
+		tb.Skip("BINARY_ASSETS_DIR is not set, the kvcl backend requires the kube-apiserver and etcd binaries")
+	}
+	virtualClusterOnce.Do(func() {
+		var kubeConfigDir string
+		if kubeConfigDir, virtualClusterErr = os.MkdirTemp("", "kvcl"); virtualClusterErr != nil {
+			return
+		}
+		vcp := kvcl.NewControlPlane(binaryAssetsDir, filepath.Join(kubeConfigDir, "kvcl-embed.yaml"))
+		if virtualClusterErr = vcp.Start(context.Background()); virtualClusterErr == nil {
+			virtualCluster = vcp
+		}
+	})
+	if virtualClusterErr != nil {
+		tb.Fatalf("cannot start virtual cluster: %v", virtualClusterErr)
+	}
+	return virtualCluster
+}
+
+// resetVirtualCluster removes all objects from the virtual cluster like the handler does before every run.
+func resetVirtualCluster(tb testing.TB, vcp kvclapi.ControlPlane) {
+	tb.Helper()
+	if err := vcp.FactoryReset(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	if err := util.DeleteAllVolumes(context.Background(), vcp.Client(), common.DefaultNamespace); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if virtualCluster != nil {
+		_ = virtualCluster.Stop()
+	}
+	os.Exit(code)
+}
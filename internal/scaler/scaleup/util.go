@@ -3,7 +3,9 @@ package scaleup
 import (
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"strings"
+	"time"
 	"unmarshall/scaling-recommender/api"
 	"unmarshall/scaling-recommender/internal/scaler"
 
@@ -36,12 +38,43 @@ func getWinningRunResult(results []*runResult) *runResult {
 	return tieBreak(winningRunResults)
 }
 
+// tieBreak prefers the candidate with the larger node capacity left after the DaemonSet overhead. Candidates with the same
+// capacity are ordered by node pool name and zone so that the winner does not depend on the order in which the candidates
+// were evaluated.
 func tieBreak(candidates []*runResult) *runResult {
 	return lo.MaxBy(candidates, func(r1 *runResult, r2 *runResult) bool {
-		return computeTotalResourceUnits(r1.nodeCapacity) > computeTotalResourceUnits(r2.nodeCapacity)
+		units1 := computeTotalResourceUnits(r1.nodeCapacity) - computeTotalResourceUnits(r1.daemonSetOverhead)
+		units2 := computeTotalResourceUnits(r2.nodeCapacity) - computeTotalResourceUnits(r2.daemonSetOverhead)
+		if units1 != units2 {
+			return units1 > units2
+		}
+		if r1.nodePoolName != r2.nodePoolName {
+			return r1.nodePoolName < r2.nodePoolName
+		}
+		return r1.zone < r2.zone
 	})
 }
 
+// randomStringAlphabet excludes vowels and look-alike characters, like the alphabet of k8s.io/apimachinery/pkg/util/rand.
+const randomStringAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// newRand creates the random source of a run. Runs without a seed are not reproducible.
+func newRand(seed *int64) *rand.Rand {
+	if seed == nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rand.New(rand.NewSource(*seed))
+}
+
+// randomString returns a random string of length n drawn from the random source of the run.
+func (r *recommender) randomString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randomStringAlphabet[r.rng.Intn(len(randomStringAlphabet))]
+	}
+	return string(b)
+}
+
 func computeTotalResourceUnits(nodeCapacity corev1.ResourceList) float64 {
 	var totalResourceUnits float64
 	totalResourceUnits += float64(nodeCapacity.Cpu().Value() * scaler.CPUResourceUnitMultiplier)
@@ -88,8 +121,8 @@ func appendScaleUpRecommendation(recommendations []api.ScaleUpRecommendation, re
 	return recommendations
 }
 
-func appendNodeUtilisationInfo(winningRunResult runResult, utilisationInfos map[string]nodeUtilisationInfo) map[string]nodeUtilisationInfo {
-	info := nodeUtilisationInfo{
+func appendNodeUtilisationInfo(winningRunResult runResult, utilisationInfos map[string]api.NodeUtilisationInfo) map[string]api.NodeUtilisationInfo {
+	info := api.NodeUtilisationInfo{
 		Zone:         winningRunResult.zone,
 		NodePoolName: winningRunResult.nodePoolName,
 		Capacity:     winningRunResult.nodeCapacity,
@@ -118,7 +151,7 @@ func appendNodeUtilisationInfo(winningRunResult runResult, utilisationInfos map[
 			utilInfo.ResourcesConsumed = *resourcesConsumed
 			utilisationInfos[nodeName] = utilInfo
 		} else {
-			utilisationInfos[nodeName] = nodeUtilisationInfo{
+			utilisationInfos[nodeName] = api.NodeUtilisationInfo{
 				Pods: lo.Map(podInfos, func(pri podResourceInfo, _ int) string {
 					return pri.name
 				}),
@@ -126,6 +159,24 @@ func appendNodeUtilisationInfo(winningRunResult runResult, utilisationInfos map[
 			}
 		}
 	}
+	if len(winningRunResult.daemonSetPods) > 0 {
+		// the DaemonSet pods consume resources of the recommended node as well.
+		utilInfo := utilisationInfos[winningRunResult.nodeName]
+		utilInfo.Pods = append(utilInfo.Pods, lo.Map(winningRunResult.daemonSetPods, func(pri podResourceInfo, _ int) string {
+			return pri.name
+		})...)
+		resourcesConsumed := utilInfo.ResourcesConsumed.DeepCopy()
+		if resourcesConsumed == nil {
+			resourcesConsumed = make(corev1.ResourceList)
+		}
+		for name, quantity := range winningRunResult.daemonSetOverhead {
+			sumQuantity := resourcesConsumed[name]
+			sumQuantity.Add(quantity)
+			resourcesConsumed[name] = sumQuantity
+		}
+		utilInfo.ResourcesConsumed = resourcesConsumed
+		utilisationInfos[winningRunResult.nodeName] = utilInfo
+	}
 	return utilisationInfos
 }
 
@@ -137,24 +188,3 @@ func fromOriginalResourceName(name, suffix string) string {
 func toOriginalResourceName(simResName string) string {
 	return strings.Split(simResName, "-sr-")[0]
 }
-
-func makeResultsLogDir() (string, error) {
-	//rootDir, err := getProjectRoot()
-	//if err != nil {
-	//	return "", err
-	//}
-	//tmpDir := filepath.Join(rootDir, "tmp")
-	//if err = os.Mkdir(tmpDir, os.ModePerm); err != nil && !os.IsExist(err) {
-	//	return "", fmt.Errorf("failed to create temp directory: %w", err)
-	//}
-	//return tmpDir, nil
-	return "/tmp", nil
-}
-
-//func getProjectRoot() (string, error) {
-//	path, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
-//	if err != nil {
-//		return "", err
-//	}
-//	return strings.TrimSpace(string(path)), nil
-//}
@@ -37,3 +37,7 @@ func (s *_scorer) Compute(scaledNode *corev1.Node, scheduledPods []*corev1.Pod)
 	}
 	return totalResourceUnitsScheduled / instanceCost
 }
+
+func (s *_scorer) Strategy() scaler.ScoringStrategy {
+	return scaler.CostOnlyStrategy
+}
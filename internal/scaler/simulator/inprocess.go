@@ -0,0 +1,245 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/unmarshall/kvcl/pkg/embed"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/events"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+
+	"unmarshall/scaling-recommender/internal/common"
+)
+
+// binPackingProfile is the scheduler profile of the embedded virtual cluster which schedules the pods of simulation runs.
+// Using the same profile keeps the recommendations of both backends comparable.
+var binPackingProfile = sync.OnceValues(func() (*schedulerconfig.KubeSchedulerProfile, error) {
+	cfg, err := embed.GetSchedulerConfig()
+	if err != nil {
+		return nil, err
+	}
+	for i := range cfg.Profiles {
+		if cfg.Profiles[i].SchedulerName == common.BinPackingSchedulerName {
+			return &cfg.Profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("scheduler profile %q not found", common.BinPackingSchedulerName)
+})
+
+// inProcessSimulator runs the PreFilter, Filter, PreScore and Score plugins of the kube-scheduler framework for one pod
+// after the other, the same way a scheduling cycle of the kube-scheduler does. Pods are bound to the node with the
+// highest score, ties are broken by node name. Neither preemption nor the binding of volumes are simulated, claims
+// which are not bound when loaded stay unbound.
+type inProcessSimulator struct {
+	base *nodeInfos
+	// workers holds one scheduler per candidate which may be evaluated concurrently. Each scheduler overlays the
+	// shared base state with its own snapshot.
+	workers     chan *inProcessScheduler
+	parallelism int
+	// profile configures the plugins of the scheduler framework. If nil, binPackingProfile is used.
+	profile *schedulerconfig.KubeSchedulerProfile
+	// cancel stops the informers and the metrics recorders of the frameworks.
+	cancel context.CancelFunc
+}
+
+type inProcessScheduler struct {
+	framework framework.Framework
+	snapshot  *snapshot
+}
+
+func newInProcessSimulator(parallelism int, profile *schedulerconfig.KubeSchedulerProfile) *inProcessSimulator {
+	return &inProcessSimulator{
+		base:        newNodeInfos(),
+		workers:     make(chan *inProcessScheduler, parallelism),
+		parallelism: parallelism,
+		profile:     profile,
+	}
+}
+
+func (s *inProcessSimulator) Initialize(ctx context.Context, state BaseState) error {
+	profile, err := s.schedulerProfile()
+	if err != nil {
+		return err
+	}
+	// plugins look up namespaces, volumes and workloads through informers, which are served by a fake client holding
+	// the storage objects of the base state.
+	objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: common.DefaultNamespace}}}
+	for _, sc := range state.StorageClasses {
+		objects = append(objects, sc.DeepCopy())
+	}
+	pvs, pvcs := simulationVolumes(state)
+	for _, pv := range pvs {
+		objects = append(objects, pv)
+	}
+	for _, pvc := range pvcs {
+		objects = append(objects, pvc)
+	}
+	clientSet := fake.NewSimpleClientset(objects...)
+	informerFactory := informers.NewSharedInformerFactory(clientSet, 0)
+	frameworkCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+	for range s.parallelism {
+		worker := &inProcessScheduler{snapshot: newSnapshot(s.base)}
+		worker.framework, err = frameworkruntime.NewFramework(frameworkCtx, plugins.NewInTreeRegistry(), profile,
+			frameworkruntime.WithClientSet(clientSet),
+			frameworkruntime.WithInformerFactory(informerFactory),
+			frameworkruntime.WithSnapshotSharedLister(worker.snapshot),
+			frameworkruntime.WithEventRecorder(&events.FakeRecorder{}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create scheduler framework: %w", err)
+		}
+		s.workers <- worker
+	}
+	informerFactory.Start(frameworkCtx.Done())
+	informerFactory.WaitForCacheSync(frameworkCtx.Done())
+
+	for _, node := range state.Nodes {
+		s.base.addNode(readyNode(node))
+	}
+	for _, pod := range state.Pods {
+		if err = s.base.addPod(withUID(pod)); err != nil {
+			return fmt.Errorf("failed to initialize simulator with scheduled pods: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *inProcessSimulator) Simulate(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*corev1.Pod, error) {
+	var worker *inProcessScheduler
+	select {
+	case worker = <-s.workers:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		worker.snapshot.resetOverlay()
+		s.workers <- worker
+	}()
+	worker.snapshot.addNode(readyNode(node))
+	result := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pod = withUID(pod)
+		nodeName := pod.Spec.NodeName
+		if nodeName == "" {
+			var err error
+			if nodeName, err = worker.schedulePod(ctx, pod); err != nil {
+				return nil, err
+			}
+		}
+		if nodeName != "" {
+			pod.Spec.NodeName = nodeName
+			if err := worker.snapshot.addPod(pod); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, pod)
+	}
+	return result, nil
+}
+
+func (s *inProcessSimulator) Commit(_ context.Context, node *corev1.Node, pods []*corev1.Pod) error {
+	s.base.addNode(readyNode(node))
+	for _, pod := range pods {
+		if err := s.base.addPod(withUID(pod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *inProcessSimulator) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *inProcessSimulator) schedulerProfile() (*schedulerconfig.KubeSchedulerProfile, error) {
+	if s.profile != nil {
+		return s.profile, nil
+	}
+	return binPackingProfile()
+}
+
+// schedulePod returns the name of the node pod is scheduled on, or an empty name if pod cannot be scheduled.
+func (s *inProcessScheduler) schedulePod(ctx context.Context, pod *corev1.Pod) (string, error) {
+	state := framework.NewCycleState()
+	preFilterResult, status := s.framework.RunPreFilterPlugins(ctx, state, pod)
+	if status.IsRejected() {
+		return "", nil
+	}
+	if !status.IsSuccess() {
+		return "", status.AsError()
+	}
+	nodeInfos, err := s.snapshot.List()
+	if err != nil {
+		return "", err
+	}
+	feasibleNodes := make([]*framework.NodeInfo, 0, len(nodeInfos))
+	for _, nodeInfo := range nodeInfos {
+		if !preFilterResult.AllNodes() && !preFilterResult.NodeNames.Has(nodeInfo.Node().Name) {
+			continue
+		}
+		status = s.framework.RunFilterPlugins(ctx, state, pod, nodeInfo)
+		if status.IsSuccess() {
+			feasibleNodes = append(feasibleNodes, nodeInfo)
+		} else if !status.IsRejected() {
+			return "", status.AsError()
+		}
+	}
+	switch len(feasibleNodes) {
+	case 0:
+		return "", nil
+	case 1:
+		return feasibleNodes[0].Node().Name, nil
+	}
+	if status = s.framework.RunPreScorePlugins(ctx, state, pod, feasibleNodes); !status.IsSuccess() {
+		return "", status.AsError()
+	}
+	nodeScores, status := s.framework.RunScorePlugins(ctx, state, pod, feasibleNodes)
+	if !status.IsSuccess() {
+		return "", status.AsError()
+	}
+	// feasible nodes are ordered by name, so the first node with the highest score wins ties.
+	best := nodeScores[0]
+	for _, nodeScore := range nodeScores[1:] {
+		if nodeScore.TotalScore > best.TotalScore {
+			best = nodeScore
+		}
+	}
+	return best.Name, nil
+}
+
+// readyNode returns a copy of node without the not-ready taint, which the kvcl backend removes once the node is created.
+func readyNode(node *corev1.Node) *corev1.Node {
+	nodeCopy := node.DeepCopy()
+	nodeCopy.Spec.Taints = nil
+	for _, taint := range node.Spec.Taints {
+		if taint.Key != common.NotReadyTaintKey {
+			nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, taint)
+		}
+	}
+	return nodeCopy
+}
+
+// withUID returns a copy of pod with a UID, which the scheduler framework uses to identify pods.
+func withUID(pod *corev1.Pod) *corev1.Pod {
+	podCopy := pod.DeepCopy()
+	if podCopy.UID == "" {
+		podCopy.UID = types.UID(podKey(podCopy))
+	}
+	return podCopy
+}
@@ -0,0 +1,336 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	kvclapi "github.com/unmarshall/kvcl/api"
+	kvcl "github.com/unmarshall/kvcl/pkg/control"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/metrics"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+const (
+	// schedulingEventsTimeout is the maximum time to wait for the scheduling events of the pods deployed for a simulation run.
+	schedulingEventsTimeout = 10 * time.Second
+	// laneKey labels the nodes and pods of a lane.
+	laneKey = "app.kubernetes.io/simulation-lane"
+)
+
+// kvclSimulator creates nodes and pods in the embedded virtual cluster and waits for the scheduling events emitted by
+// its kube-scheduler.
+//
+// Candidates which are evaluated concurrently must not see each other's pods. If more than one candidate may be evaluated
+// at a time, the base state is therefore cloned into one lane per worker. The nodes and pods of a lane carry the name of
+// the lane as suffix and label, pods select the nodes of their lane and their topology spread constraints and pod
+// (anti-)affinity terms only match pods of their lane. Persistent volumes and claims are cloned with the name of the lane
+// as suffix as well and pods mount the claims of their lane. Storage classes are shared by all lanes.
+type kvclSimulator struct {
+	nc       kvclapi.NodeControl
+	pc       kvclapi.PodControl
+	ec       kvclapi.EventControl
+	client   client.Client
+	strategy string
+	// lanes holds the names of the lanes which are currently not used by a candidate. The only lane of a simulator
+	// which evaluates one candidate at a time is the base state itself and has an empty name.
+	lanes     chan string
+	laneNames []string
+}
+
+func newKVCLSimulator(vcp kvclapi.ControlPlane, strategy string, parallelism int) *kvclSimulator {
+	laneNames := []string{""}
+	if parallelism > 1 {
+		laneNames = make([]string, 0, parallelism)
+		for i := range parallelism {
+			laneNames = append(laneNames, fmt.Sprintf("l%d", i))
+		}
+	}
+	lanes := make(chan string, len(laneNames))
+	for _, laneName := range laneNames {
+		lanes <- laneName
+	}
+	return &kvclSimulator{
+		nc:        vcp.NodeControl(),
+		pc:        vcp.PodControl(),
+		ec:        vcp.EventControl(),
+		client:    vcp.Client(),
+		strategy:  strategy,
+		lanes:     lanes,
+		laneNames: laneNames,
+	}
+}
+
+func (s *kvclSimulator) Initialize(ctx context.Context, state BaseState) error {
+	for _, pc := range state.PriorityClasses {
+		if err := s.client.Create(ctx, &pc); err != nil {
+			return fmt.Errorf("failed to initialize virtual cluster with priority class: %w", err)
+		}
+	}
+	for _, sc := range state.StorageClasses {
+		scCopy := sc.DeepCopy()
+		scCopy.ObjectMeta = newObjectMeta(sc.ObjectMeta, "")
+		if err := s.client.Create(ctx, scCopy); err != nil {
+			return fmt.Errorf("failed to initialize virtual cluster with storage class: %w", err)
+		}
+	}
+	pvs, pvcs := simulationVolumes(state)
+	for _, laneName := range s.laneNames {
+		if err := s.createVolumes(ctx, toLaneVolumes(pvs, laneName), toLaneClaims(pvcs, laneName)); err != nil {
+			return fmt.Errorf("failed to initialize virtual cluster with volumes: %w", err)
+		}
+		if state.Nodes != nil {
+			nodes := lo.Map(state.Nodes, func(node *corev1.Node, _ int) *corev1.Node {
+				return toLaneNode(node, laneName)
+			})
+			if err := util.CreateAndUntaintNodes(ctx, s.client, nodes); err != nil {
+				return fmt.Errorf("failed to initialize virtual cluster with existing nodes: %w", err)
+			}
+		}
+		if state.Pods != nil {
+			if err := s.pc.CreatePods(ctx, toLanePods(state.Pods, laneName)...); err != nil {
+				return fmt.Errorf("failed to initialize virtual cluster with scheduled pods: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *kvclSimulator) createVolumes(ctx context.Context, pvs []*corev1.PersistentVolume, pvcs []*corev1.PersistentVolumeClaim) error {
+	for _, pv := range pvs {
+		if err := s.client.Create(ctx, pv); err != nil {
+			return err
+		}
+	}
+	for _, pvc := range pvcs {
+		if err := s.client.Create(ctx, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *kvclSimulator) Simulate(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*corev1.Pod, error) {
+	var laneName string
+	select {
+	case laneName = <-s.lanes:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		s.lanes <- laneName
+	}()
+	laneNode := toLaneNode(node, laneName)
+	lanePods := toLanePods(pods, laneName)
+	var podNames []string
+	defer s.cleanUp(context.WithoutCancel(ctx), laneNode.Name, &podNames)
+	if err := kvcl.CreateAndUntaintNode(ctx, s.nc, common.NotReadyTaintKey, laneNode); err != nil {
+		return nil, err
+	}
+	deployTime := time.Now()
+	if err := s.pc.CreatePods(ctx, lanePods...); err != nil {
+		return nil, err
+	}
+	podNames = util.GetPodNames(lanePods)
+	// bound pods are not scheduled and emit no scheduling events.
+	podsToSchedule := lo.Filter(lanePods, func(pod *corev1.Pod, _ int) bool {
+		return pod.Spec.NodeName == ""
+	})
+	scheduledPodNames, _, err := s.ec.GetPodSchedulingEvents(ctx, common.DefaultNamespace, deployTime, podsToSchedule, waitTimeoutForSchedulingEvents(ctx))
+	metrics.SchedulingEventsWaitDuration.WithLabelValues(s.strategy).Observe(time.Since(deployTime).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	scheduledPods, err := s.pc.GetPodsMatchingPodNames(ctx, common.DefaultNamespace, scheduledPodNames.UnsortedList()...)
+	if err != nil {
+		return nil, err
+	}
+	return fromLanePods(pods, scheduledPods, laneName), nil
+}
+
+func (s *kvclSimulator) Commit(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) error {
+	for _, laneName := range s.laneNames {
+		if err := s.pc.CreatePods(ctx, toLanePods(pods, laneName)...); err != nil {
+			return err
+		}
+		if err := kvcl.CreateAndUntaintNode(ctx, s.nc, common.NotReadyTaintKey, toLaneNode(node, laneName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close does nothing, the virtual cluster is reset before every recommender run.
+func (s *kvclSimulator) Close() {}
+
+func (s *kvclSimulator) cleanUp(ctx context.Context, nodeName string, podNames *[]string) {
+	if err := s.nc.DeleteNodes(ctx, nodeName); err != nil {
+		slog.Error("Failed to delete node", "nodeName", nodeName, "error", err)
+	}
+	if len(*podNames) > 0 {
+		if err := s.pc.DeletePodsMatchingNames(ctx, common.DefaultNamespace, *podNames...); err != nil {
+			slog.Error("Failed to delete pods", "podNames", *podNames, "error", err)
+		}
+	}
+}
+
+// waitTimeoutForSchedulingEvents caps schedulingEventsTimeout to the time left until the deadline of ctx.
+func waitTimeoutForSchedulingEvents(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return schedulingEventsTimeout
+	}
+	return max(min(schedulingEventsTimeout, time.Until(deadline)), 0)
+}
+
+func toLaneName(name, laneName string) string {
+	if laneName == "" || name == "" {
+		return name
+	}
+	return name + "-" + laneName
+}
+
+func fromLaneName(name, laneName string) string {
+	if laneName == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, "-"+laneName)
+}
+
+// toLaneNode returns the copy of node in the lane, or node itself for the unnamed lane.
+func toLaneNode(node *corev1.Node, laneName string) *corev1.Node {
+	if laneName == "" {
+		return node
+	}
+	nodeCopy := node.DeepCopy()
+	nodeCopy.Name = toLaneName(node.Name, laneName)
+	if nodeCopy.Labels == nil {
+		nodeCopy.Labels = make(map[string]string)
+	}
+	nodeCopy.Labels[laneKey] = laneName
+	nodeCopy.Labels[common.TopologyHostLabelKey] = nodeCopy.Name
+	nodeCopy.ObjectMeta.UID = ""
+	nodeCopy.ObjectMeta.ResourceVersion = ""
+	nodeCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
+	return nodeCopy
+}
+
+// toLanePods returns the copies of pods in the lane, or pods themselves for the unnamed lane.
+func toLanePods(pods []*corev1.Pod, laneName string) []*corev1.Pod {
+	if laneName == "" {
+		return pods
+	}
+	lanePods := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		podCopy := pod.DeepCopy()
+		podCopy.Name = toLaneName(pod.Name, laneName)
+		podCopy.Spec.NodeName = toLaneName(pod.Spec.NodeName, laneName)
+		if podCopy.Labels == nil {
+			podCopy.Labels = make(map[string]string)
+		}
+		podCopy.Labels[laneKey] = laneName
+		if podCopy.Spec.NodeSelector == nil {
+			podCopy.Spec.NodeSelector = make(map[string]string)
+		}
+		podCopy.Spec.NodeSelector[laneKey] = laneName
+		for i := range podCopy.Spec.Volumes {
+			if pvcSource := podCopy.Spec.Volumes[i].PersistentVolumeClaim; pvcSource != nil {
+				pvcSource.ClaimName = toLaneName(pvcSource.ClaimName, laneName)
+			}
+		}
+		for i := range podCopy.Spec.TopologySpreadConstraints {
+			tsc := &podCopy.Spec.TopologySpreadConstraints[i]
+			tsc.LabelSelector = withLaneSelector(tsc.LabelSelector, laneName)
+		}
+		if affinity := podCopy.Spec.Affinity; affinity != nil {
+			if affinity.PodAffinity != nil {
+				addLaneSelectors(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, laneName)
+			}
+			if affinity.PodAntiAffinity != nil {
+				addLaneSelectors(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, laneName)
+			}
+		}
+		podCopy.ObjectMeta.UID = ""
+		podCopy.ObjectMeta.ResourceVersion = ""
+		podCopy.ObjectMeta.CreationTimestamp = metav1.Time{}
+		lanePods = append(lanePods, podCopy)
+	}
+	return lanePods
+}
+
+// toLaneVolumes returns the copies of pvs in the lane, or pvs themselves for the unnamed lane.
+func toLaneVolumes(pvs []*corev1.PersistentVolume, laneName string) []*corev1.PersistentVolume {
+	if laneName == "" {
+		return pvs
+	}
+	lanePVs := make([]*corev1.PersistentVolume, 0, len(pvs))
+	for _, pv := range pvs {
+		pvCopy := pv.DeepCopy()
+		pvCopy.Name = toLaneName(pv.Name, laneName)
+		if pvCopy.Spec.ClaimRef != nil {
+			pvCopy.Spec.ClaimRef.Name = toLaneName(pvCopy.Spec.ClaimRef.Name, laneName)
+		}
+		lanePVs = append(lanePVs, pvCopy)
+	}
+	return lanePVs
+}
+
+// toLaneClaims returns the copies of pvcs in the lane, or pvcs themselves for the unnamed lane.
+func toLaneClaims(pvcs []*corev1.PersistentVolumeClaim, laneName string) []*corev1.PersistentVolumeClaim {
+	if laneName == "" {
+		return pvcs
+	}
+	lanePVCs := make([]*corev1.PersistentVolumeClaim, 0, len(pvcs))
+	for _, pvc := range pvcs {
+		pvcCopy := pvc.DeepCopy()
+		pvcCopy.Name = toLaneName(pvc.Name, laneName)
+		pvcCopy.Spec.VolumeName = toLaneName(pvc.Spec.VolumeName, laneName)
+		lanePVCs = append(lanePVCs, pvcCopy)
+	}
+	return lanePVCs
+}
+
+func addLaneSelectors(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm, laneName string) {
+	for i := range required {
+		required[i].LabelSelector = withLaneSelector(required[i].LabelSelector, laneName)
+	}
+	for i := range preferred {
+		preferred[i].PodAffinityTerm.LabelSelector = withLaneSelector(preferred[i].PodAffinityTerm.LabelSelector, laneName)
+	}
+}
+
+// withLaneSelector restricts selector to the pods of the lane. A nil selector matches no pods and is kept as is.
+func withLaneSelector(selector *metav1.LabelSelector, laneName string) *metav1.LabelSelector {
+	if selector == nil {
+		return nil
+	}
+	if selector.MatchLabels == nil {
+		selector.MatchLabels = make(map[string]string)
+	}
+	selector.MatchLabels[laneKey] = laneName
+	return selector
+}
+
+// fromLanePods returns copies of the original pods, the scheduled ones with the name of the original node they are bound to.
+func fromLanePods(original []*corev1.Pod, scheduled []*corev1.Pod, laneName string) []*corev1.Pod {
+	scheduledByName := lo.SliceToMap(scheduled, func(pod *corev1.Pod) (string, *corev1.Pod) {
+		return fromLaneName(pod.Name, laneName), pod
+	})
+	pods := make([]*corev1.Pod, 0, len(original))
+	for _, pod := range original {
+		podCopy := pod.DeepCopy()
+		if scheduledPod, ok := scheduledByName[pod.Name]; ok {
+			podCopy.Spec.NodeName = fromLaneName(scheduledPod.Spec.NodeName, laneName)
+		}
+		pods = append(pods, podCopy)
+	}
+	return pods
+}
@@ -0,0 +1,72 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerv1 "k8s.io/kube-scheduler/config/v1"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	schedulerconfigscheme "k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/validation"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+)
+
+// LoadSchedulerProfile reads the KubeSchedulerConfiguration at path and returns its first profile. Plugins and plugin
+// arguments which are not configured are defaulted the same way the kube-scheduler does.
+func LoadSchedulerProfile(path string) (*schedulerconfig.KubeSchedulerProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduler configuration: %w", err)
+	}
+	return decodeSchedulerProfile(data)
+}
+
+// ConvertSchedulerProfile defaults and validates a profile passed with a simulation request.
+func ConvertSchedulerProfile(profile schedulerv1.KubeSchedulerProfile) (*schedulerconfig.KubeSchedulerProfile, error) {
+	data, err := json.Marshal(schedulerv1.KubeSchedulerConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: schedulerv1.SchemeGroupVersion.String(), Kind: "KubeSchedulerConfiguration"},
+		Profiles: []schedulerv1.KubeSchedulerProfile{profile},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeSchedulerProfile(data)
+}
+
+func decodeSchedulerProfile(data []byte) (*schedulerconfig.KubeSchedulerProfile, error) {
+	obj, gvk, err := schedulerconfigscheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scheduler configuration: %w", err)
+	}
+	cfg, ok := obj.(*schedulerconfig.KubeSchedulerConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("expected a KubeSchedulerConfiguration, got %s", gvk)
+	}
+	cfg.TypeMeta.APIVersion = gvk.GroupVersion().String()
+	if err = validation.ValidateKubeSchedulerConfiguration(cfg); err != nil {
+		return nil, fmt.Errorf("invalid scheduler configuration: %w", err)
+	}
+	profile := &cfg.Profiles[0]
+	if err = checkPluginsRegistered(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// checkPluginsRegistered rejects profiles enabling plugins which are not in-tree plugins. Validation does not cover them,
+// the scheduler framework would only fail once it is created for a recommender run.
+func checkPluginsRegistered(profile *schedulerconfig.KubeSchedulerProfile) error {
+	registry := plugins.NewInTreeRegistry()
+	pluginNames := profile.Plugins.Names()
+	for _, plugin := range profile.Plugins.MultiPoint.Enabled {
+		pluginNames = append(pluginNames, plugin.Name)
+	}
+	for _, pluginName := range pluginNames {
+		if _, ok := registry[pluginName]; !ok {
+			return fmt.Errorf("invalid scheduler configuration: plugin %q of profile %q is not an in-tree plugin", pluginName, profile.SchedulerName)
+		}
+	}
+	return nil
+}
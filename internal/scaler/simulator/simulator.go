@@ -0,0 +1,91 @@
+// Package simulator schedules pods onto a simulated cluster for the recommender. The kvcl backend runs the real
+// kube-scheduler against the embedded virtual cluster and serves as the reference, the in-process backend runs the
+// filter and score plugins of the kube-scheduler framework directly over in-memory nodes and pods.
+package simulator
+
+import (
+	"context"
+	"fmt"
+
+	kvclapi "github.com/unmarshall/kvcl/api"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+)
+
+// Backend is the implementation used to simulate the scheduling of pods.
+type Backend string
+
+const (
+	// KVCLBackend schedules pods with the kube-scheduler of the embedded virtual cluster.
+	KVCLBackend Backend = "kvcl"
+	// InProcessBackend schedules pods with the kube-scheduler framework running in the recommender process.
+	InProcessBackend Backend = "in-process"
+)
+
+var backends = sets.New(string(KVCLBackend), string(InProcessBackend))
+
+// IsBackendSupported checks if the passed in simulator backend is supported.
+func IsBackendSupported(backend string) bool {
+	return backends.Has(backend)
+}
+
+// SupportsSchedulerProfiles reports whether the backend can schedule pods with a scheduler profile other than the
+// bin-packing profile of kvcl. The kube-scheduler of kvcl is started with an embedded configuration which cannot be changed.
+func (b Backend) SupportsSchedulerProfiles() bool {
+	return b == InProcessBackend
+}
+
+// BaseState is the state of the cluster against which candidates are evaluated.
+type BaseState struct {
+	Nodes []*corev1.Node
+	// Pods are the pods which are bound to one of the Nodes.
+	Pods            []*corev1.Pod
+	PriorityClasses []schedulingv1.PriorityClass
+	// PersistentVolumes, PersistentVolumeClaims and StorageClasses are the storage objects referenced by the volumes of
+	// pods. Claims which are not bound yet are bound to a volume provisioned for them by their storage class.
+	PersistentVolumes      []corev1.PersistentVolume
+	PersistentVolumeClaims []corev1.PersistentVolumeClaim
+	StorageClasses         []storagev1.StorageClass
+}
+
+// Simulator simulates the scheduling of pods for a single recommender run. Simulate may be called concurrently by as many
+// goroutines as the parallelism the simulator was created with, all other methods must not be called concurrently.
+type Simulator interface {
+	// Initialize loads the base state into the simulator. It has to be called once before any other method.
+	Initialize(ctx context.Context, state BaseState) error
+	// Simulate adds node to the base state and schedules pods one after the other in the given order. Pods which are
+	// already bound to node, like DaemonSet pods, are placed on it without being scheduled and have to precede all other
+	// pods. It returns the pods in the same order, the scheduled ones with their node name set. The base state is left
+	// unchanged.
+	Simulate(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*corev1.Pod, error)
+	// Commit adds node and pods, which are already bound to a node, to the base state.
+	Commit(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) error
+	// Close releases the resources held by the simulator.
+	Close()
+}
+
+// New creates the Simulator of a single recommender run which evaluates up to parallelism candidates concurrently. The
+// control plane is only used by the kvcl backend, strategy labels the metrics recorded by the simulator. Pods are
+// scheduled with profile, or with the bin-packing profile of kvcl if profile is nil.
+func New(backend Backend, vcp kvclapi.ControlPlane, strategy string, parallelism int, profile *schedulerconfig.KubeSchedulerProfile) (Simulator, error) {
+	if parallelism < 1 {
+		return nil, fmt.Errorf("parallelism must be at least 1, got %d", parallelism)
+	}
+	if profile != nil && !backend.SupportsSchedulerProfiles() {
+		return nil, fmt.Errorf("simulator backend %q does not support scheduler profiles", backend)
+	}
+	switch backend {
+	case KVCLBackend:
+		if vcp == nil {
+			return nil, fmt.Errorf("simulator backend %q requires the virtual cluster", backend)
+		}
+		return newKVCLSimulator(vcp, strategy, parallelism), nil
+	case InProcessBackend:
+		return newInProcessSimulator(parallelism, profile), nil
+	default:
+		return nil, fmt.Errorf("simulator backend not supported: %s", backend)
+	}
+}
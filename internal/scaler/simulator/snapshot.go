@@ -0,0 +1,171 @@
+package simulator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// nodeInfos holds the NodeInfos of the base state. It is shared by the snapshots of all workers and only changed while
+// no candidate is evaluated.
+type nodeInfos struct {
+	byName map[string]*framework.NodeInfo
+	// names are the names of all nodes in sorted order.
+	names []string
+}
+
+func newNodeInfos() *nodeInfos {
+	return &nodeInfos{byName: make(map[string]*framework.NodeInfo)}
+}
+
+func (n *nodeInfos) addNode(node *corev1.Node) {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	n.byName[node.Name] = nodeInfo
+	if idx, found := slices.BinarySearch(n.names, node.Name); !found {
+		n.names = slices.Insert(n.names, idx, node.Name)
+	}
+}
+
+func (n *nodeInfos) addPod(pod *corev1.Pod) error {
+	nodeInfo, ok := n.byName[pod.Spec.NodeName]
+	if !ok {
+		return fmt.Errorf("node %q of pod %q not found", pod.Spec.NodeName, pod.Name)
+	}
+	nodeInfo.AddPod(pod)
+	return nil
+}
+
+// snapshot holds the nodes and pods seen by the scheduler framework of a worker. Changes made while a candidate is
+// evaluated are kept in an overlay of copied NodeInfos so that the base state can be restored by dropping the overlay.
+type snapshot struct {
+	base    *nodeInfos
+	overlay map[string]*framework.NodeInfo
+	// nodeNames are the names of all nodes in base and overlay in sorted order. It is nil if the overlay holds no nodes
+	// which are not part of base.
+	nodeNames []string
+}
+
+var (
+	_ framework.SharedLister      = (*snapshot)(nil)
+	_ framework.NodeInfoLister    = (*snapshot)(nil)
+	_ framework.StorageInfoLister = (*snapshot)(nil)
+)
+
+func newSnapshot(base *nodeInfos) *snapshot {
+	return &snapshot{
+		base:    base,
+		overlay: make(map[string]*framework.NodeInfo),
+	}
+}
+
+// addNode adds node to the overlay.
+func (s *snapshot) addNode(node *corev1.Node) {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	s.overlay[node.Name] = nodeInfo
+	nodeNames := s.names()
+	if idx, found := slices.BinarySearch(nodeNames, node.Name); !found {
+		s.nodeNames = slices.Insert(slices.Clone(nodeNames), idx, node.Name)
+	}
+}
+
+// addPod adds pod to a copy of the NodeInfo of the node it is bound to.
+func (s *snapshot) addPod(pod *corev1.Pod) error {
+	nodeName := pod.Spec.NodeName
+	nodeInfo, ok := s.overlay[nodeName]
+	if !ok {
+		baseNodeInfo, ok := s.base.byName[nodeName]
+		if !ok {
+			return fmt.Errorf("node %q of pod %q not found", nodeName, pod.Name)
+		}
+		nodeInfo = baseNodeInfo.Snapshot()
+		s.overlay[nodeName] = nodeInfo
+	}
+	nodeInfo.AddPod(pod)
+	return nil
+}
+
+// resetOverlay drops all changes made since the last reset.
+func (s *snapshot) resetOverlay() {
+	clear(s.overlay)
+	s.nodeNames = nil
+}
+
+func (s *snapshot) names() []string {
+	if s.nodeNames != nil {
+		return s.nodeNames
+	}
+	return s.base.names
+}
+
+func (s *snapshot) NodeInfos() framework.NodeInfoLister {
+	return s
+}
+
+func (s *snapshot) StorageInfos() framework.StorageInfoLister {
+	return s
+}
+
+// List returns the NodeInfos of all nodes ordered by node name.
+func (s *snapshot) List() ([]*framework.NodeInfo, error) {
+	nodeNames := s.names()
+	nodeInfos := make([]*framework.NodeInfo, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		nodeInfo, _ := s.Get(nodeName)
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	return nodeInfos, nil
+}
+
+func (s *snapshot) HavePodsWithAffinityList() ([]*framework.NodeInfo, error) {
+	return s.filter(func(nodeInfo *framework.NodeInfo) bool {
+		return len(nodeInfo.PodsWithAffinity) > 0
+	}), nil
+}
+
+func (s *snapshot) HavePodsWithRequiredAntiAffinityList() ([]*framework.NodeInfo, error) {
+	return s.filter(func(nodeInfo *framework.NodeInfo) bool {
+		return len(nodeInfo.PodsWithRequiredAntiAffinity) > 0
+	}), nil
+}
+
+func (s *snapshot) Get(nodeName string) (*framework.NodeInfo, error) {
+	if nodeInfo, ok := s.overlay[nodeName]; ok {
+		return nodeInfo, nil
+	}
+	if nodeInfo, ok := s.base.byName[nodeName]; ok {
+		return nodeInfo, nil
+	}
+	return nil, fmt.Errorf("nodeinfo not found for node name %q", nodeName)
+}
+
+// IsPVCUsedByPods reports whether a pod on any node uses the PVC with the given key in the format namespace/name.
+func (s *snapshot) IsPVCUsedByPods(key string) bool {
+	for _, nodeName := range s.names() {
+		nodeInfo, _ := s.Get(nodeName)
+		if nodeInfo.PVCRefCounts[key] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *snapshot) filter(predicate func(nodeInfo *framework.NodeInfo) bool) []*framework.NodeInfo {
+	var nodeInfos []*framework.NodeInfo
+	for _, nodeName := range s.names() {
+		nodeInfo, _ := s.Get(nodeName)
+		if predicate(nodeInfo) {
+			nodeInfos = append(nodeInfos, nodeInfo)
+		}
+	}
+	return nodeInfos
+}
+
+// podKey is the key of a pod in the scheduler framework, which requires pods to have a UID.
+func podKey(pod *corev1.Pod) string {
+	return strings.Join([]string{pod.Namespace, pod.Name}, "/")
+}
@@ -0,0 +1,113 @@
+package simulator
+
+import (
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	storagehelpers "k8s.io/component-helpers/storage/volume"
+
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+// provisionedVolumePrefix prefixes the name of the claim a volume is provisioned for, dynamic provisioners name volumes
+// after the UID of the claim the same way.
+const provisionedVolumePrefix = "pvc-"
+
+// simulationVolumes returns copies of the persistent volumes and claims of state as they are loaded into the simulator.
+// Claims are moved to the namespace of the simulated pods.
+//
+// Claims which are not bound yet are bound to a volume provisioned for them by the CSI driver of their storage class. For
+// such claims the volume binding plugin only checks that the volume can be provisioned in the topology of a node, which
+// the provisioned volume reproduces with a node affinity to the allowed topologies of the storage class. The CSI driver of
+// the volume counts against the same attach limit as the provisioner of the storage class. Binding the claims up front
+// keeps the kube-scheduler of kvcl, which runs without volume controllers, from waiting for the volumes to be provisioned.
+// Claims of storage classes whose provisioner is not a CSI driver are left unbound.
+func simulationVolumes(state BaseState) ([]*corev1.PersistentVolume, []*corev1.PersistentVolumeClaim) {
+	pvs := make([]*corev1.PersistentVolume, 0, len(state.PersistentVolumes))
+	for _, pv := range state.PersistentVolumes {
+		pvCopy := &corev1.PersistentVolume{
+			ObjectMeta: newObjectMeta(pv.ObjectMeta, ""),
+			Spec:       *pv.Spec.DeepCopy(),
+		}
+		if claimRef := pvCopy.Spec.ClaimRef; claimRef != nil {
+			claimRef.Namespace = common.DefaultNamespace
+			claimRef.UID = ""
+			claimRef.ResourceVersion = ""
+		}
+		pvs = append(pvs, pvCopy)
+	}
+	pvcs := make([]*corev1.PersistentVolumeClaim, 0, len(state.PersistentVolumeClaims))
+	for _, pvc := range state.PersistentVolumeClaims {
+		pvcCopy := &corev1.PersistentVolumeClaim{
+			ObjectMeta: newObjectMeta(pvc.ObjectMeta, common.DefaultNamespace),
+			Spec:       *pvc.Spec.DeepCopy(),
+		}
+		if pvcCopy.Spec.VolumeName == "" {
+			if sc := util.FindStorageClass(state.StorageClasses, pvcCopy); sc != nil {
+				if driver := util.GetCSIDriverName(sc.Provisioner); driver != "" {
+					pv := provisionVolume(pvcCopy, sc, driver)
+					pvcCopy.Spec.VolumeName = pv.Name
+					pvs = append(pvs, pv)
+				}
+			}
+		}
+		if pvcCopy.Spec.VolumeName != "" {
+			// the volume binding plugin treats claims as bound only once the binding has been completed.
+			metav1.SetMetaDataAnnotation(&pvcCopy.ObjectMeta, storagehelpers.AnnBindCompleted, "yes")
+		}
+		pvcs = append(pvcs, pvcCopy)
+	}
+	return pvs, pvcs
+}
+
+// provisionVolume returns the volume which driver provisions for claim in the allowed topologies of sc.
+func provisionVolume(claim *corev1.PersistentVolumeClaim, sc *storagev1.StorageClass, driver string) *corev1.PersistentVolume {
+	name := provisionedVolumePrefix + claim.Name
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{storagehelpers.AnnDynamicallyProvisioned: driver},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: claim.Spec.Resources.Requests[corev1.ResourceStorage]},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name},
+			},
+			AccessModes:                   claim.Spec.AccessModes,
+			ClaimRef:                      &corev1.ObjectReference{Kind: "PersistentVolumeClaim", APIVersion: "v1", Namespace: claim.Namespace, Name: claim.Name},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			StorageClassName:              sc.Name,
+			VolumeMode:                    claim.Spec.VolumeMode,
+		},
+	}
+	if len(sc.AllowedTopologies) == 0 {
+		return pv
+	}
+	terms := make([]corev1.NodeSelectorTerm, 0, len(sc.AllowedTopologies))
+	for _, topology := range sc.AllowedTopologies {
+		var term corev1.NodeSelectorTerm
+		for _, requirement := range topology.MatchLabelExpressions {
+			term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+				Key:      requirement.Key,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   requirement.Values,
+			})
+		}
+		terms = append(terms, term)
+	}
+	pv.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{NodeSelectorTerms: terms}}
+	return pv
+}
+
+// newObjectMeta returns the object meta of a copy of an object in namespace which can be created in the simulator.
+func newObjectMeta(objectMeta metav1.ObjectMeta, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        objectMeta.Name,
+		Namespace:   namespace,
+		Labels:      maps.Clone(objectMeta.Labels),
+		Annotations: maps.Clone(objectMeta.Annotations),
+	}
+}
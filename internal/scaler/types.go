@@ -45,6 +45,8 @@ type ScorerFactory interface {
 
 type Scorer interface {
 	Compute(scaledNode *corev1.Node, candidatePods []*corev1.Pod) float64
+	// Strategy returns the scoring strategy implemented by the Scorer.
+	Strategy() ScoringStrategy
 }
 
 type LogWriterFlusher interface {
@@ -52,17 +54,36 @@ type LogWriterFlusher interface {
 	http.Flusher
 }
 
+// ProgressReporter receives the progress events emitted by a Recommender while it runs.
+type ProgressReporter interface {
+	Report(event api.ProgressEvent)
+}
+
+// NoopProgressReporter is a ProgressReporter which discards all events.
+type NoopProgressReporter struct{}
+
+func (NoopProgressReporter) Report(api.ProgressEvent) {}
+
 type RecommenderFactory interface {
 	GetRecommender(variant AlgoVariant) Recommender
 }
 
 type Recommender interface {
-	Run(ctx context.Context, scorer Scorer, simReq api.SimulationRequest) Result
+	// Run computes the scale-up recommendation for simReq. If ctx has a deadline then no new round is started once it
+	// has passed and the recommendations computed so far are returned as a partial result.
+	Run(ctx context.Context, scorer Scorer, simReq api.SimulationRequest, reporter ProgressReporter) Result
 }
 
 type OkResult struct {
 	Recommendation  api.Recommendation
 	UnscheduledPods []client.ObjectKey
+	// Scores are the scores of all candidates per round of the recommender.
+	Scores []api.RunResultScores
+	// NodeUtilisation is keyed on the node name and describes how the recommended and existing nodes are utilised.
+	NodeUtilisation map[string]api.NodeUtilisationInfo
+	// PartialReason is set if the recommender stopped before all pods were considered, e.g. because the deadline of the run
+	// expired. Recommendation then contains the scale-ups computed until then.
+	PartialReason string
 }
 
 type Result struct {
@@ -86,3 +107,10 @@ func OkScaleUpResult(recommendations []api.ScaleUpRecommendation, unscheduledPod
 		},
 	}
 }
+
+// PartialScaleUpResult is an OkScaleUpResult for a run which stopped early for the given reason.
+func PartialScaleUpResult(recommendations []api.ScaleUpRecommendation, unscheduledPods []client.ObjectKey, reason string) Result {
+	result := OkScaleUpResult(recommendations, unscheduledPods)
+	result.Ok.PartialReason = reason
+	return result
+}
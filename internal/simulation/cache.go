@@ -0,0 +1,105 @@
+package simulation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/cache"
+	"unmarshall/scaling-recommender/internal/metrics"
+)
+
+const (
+	// cacheStatusHeader tells whether a response was served from the response cache.
+	cacheStatusHeader = "X-Cache"
+	cacheHit          = "HIT"
+	cacheMiss         = "MISS"
+)
+
+// responseCache caches the responses of recommendations computed from the pods of a snapshot. A nil responseCache caches nothing.
+type responseCache struct {
+	lru *cache.LRU[string, api.RecommendationResponse]
+}
+
+// newResponseCache creates a cache holding up to size responses for ttl each. It returns nil if size is zero.
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	if size <= 0 {
+		return nil
+	}
+	return &responseCache{lru: cache.NewLRU[string, api.RecommendationResponse](size, ttl)}
+}
+
+func (c *responseCache) get(key string) (api.RecommendationResponse, bool) {
+	if c == nil {
+		return api.RecommendationResponse{}, false
+	}
+	response, ok := c.lru.Get(key)
+	if ok {
+		metrics.ResponseCacheLookups.WithLabelValues(metrics.ResultCacheHit).Inc()
+	} else {
+		metrics.ResponseCacheLookups.WithLabelValues(metrics.ResultCacheMiss).Inc()
+	}
+	return response, ok
+}
+
+// add caches response unless it is partial, as a partial response depends on the deadline of the request and not only on its content.
+func (c *responseCache) add(key string, response api.RecommendationResponse) {
+	if c == nil || response.Partial {
+		return
+	}
+	c.lru.Add(key, response)
+}
+
+// cacheKeyInput lists everything the recommendation depends on.
+type cacheKeyInput struct {
+	SimulationRequest api.SimulationRequest `json:"simulationRequest"`
+	ScoringStrategy   string                `json:"scoringStrategy"`
+	PricingVersion    string                `json:"pricingVersion"`
+	AppVersion        string                `json:"appVersion"`
+}
+
+// computeCacheKey hashes a canonical form of the simulation request together with the scoring strategy and the versions of
+// the pricing catalog and the recommender. The ID of the request and the order of node pools and priority classes do not
+// change the recommendation and are therefore not part of the key. The order of pods and nodes is kept as it can decide
+// between equally sized pods.
+func computeCacheKey(simRequest api.SimulationRequest, scoringStrategy, pricingVersion, appVersion string) (string, error) {
+	simRequest.ID = ""
+	simRequest.NodePools = slices.Clone(simRequest.NodePools)
+	slices.SortFunc(simRequest.NodePools, func(a, b api.NodePool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	simRequest.PriorityClasses = slices.Clone(simRequest.PriorityClasses)
+	slices.SortFunc(simRequest.PriorityClasses, func(a, b schedulingv1.PriorityClass) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	data, err := json.Marshal(cacheKeyInput{
+		SimulationRequest: simRequest,
+		ScoringStrategy:   scoringStrategy,
+		PricingVersion:    pricingVersion,
+		AppVersion:        appVersion,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// isCacheBypassed reports whether the client asked to recompute the recommendation with Cache-Control: no-cache.
+func isCacheBypassed(r *http.Request) bool {
+	for _, value := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return false
+}
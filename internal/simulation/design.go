@@ -0,0 +1,295 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	gsc "github.com/elankath/gardener-scaling-common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	resourcehelper "k8s.io/kubernetes/pkg/api/v1/resource"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/pricing"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
+	"unmarshall/scaling-recommender/internal/simulation/web"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+// designedPoolPrefix prefixes the names of designed worker pools so that they never clash with the existing node pools.
+const designedPoolPrefix = "design-"
+
+// design proposes new worker pools, chosen among the instance types of the pricing catalog, for the pending pods of a
+// simulation request and compares their cost with the scale-up of the existing node pools.
+func (h *Handler) design(w http.ResponseWriter, r *http.Request) {
+	defer closeRequestBody(r)
+
+	designRequest, err := web.ParseDesignRequest(r.Body)
+	if err != nil {
+		slog.Info("error parsing design request", "error", err)
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = h.normalizeSimulationRequest(&designRequest.SimulationRequest); err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if designRequest.SystemOverhead == nil {
+		designRequest.SystemOverhead = h.systemOverhead
+	}
+	normalizeDesignRequest(designRequest)
+	if validationErrs := validateDesignRequest(designRequest, h.backend); len(validationErrs) > 0 {
+		web.ValidationErrorResponse(w, validationErrs)
+		return
+	}
+	deadline, err := parseDeadline(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	seed, err := parseSeed(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scorer, err := h.parseScorer(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if seed == nil {
+		seed = designRequest.Seed
+	}
+	if seed == nil {
+		// both recommendations run with the same seed so that the response tells how to reproduce them.
+		seed = new(int64)
+		*seed = time.Now().UnixNano()
+	}
+	opts := runOptions{podSource: api.PodSourceSnapshot, deadline: deadline, seed: seed, bypassCache: isCacheBypassed(r), input: designRequest, scorer: scorer}
+	response, err := h.runDesign(r.Context(), *designRequest, opts)
+	if err != nil {
+		web.ErrorResponse(w, web.StatusCodeForError(err), err.Error())
+		return
+	}
+	if err = web.WriteJSON(w, http.StatusOK, response); err != nil {
+		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// runDesign runs the recommender twice, once for the existing node pools and once for a hypothetical pool per candidate
+// instance type of the pricing catalog.
+func (h *Handler) runDesign(ctx context.Context, designRequest api.DesignRequest, opts runOptions) (api.DesignResponse, error) {
+	startTime := time.Now()
+	existingResponse, err := h.runRecommender(ctx, designRequest.SimulationRequest, opts)
+	if err != nil {
+		return api.DesignResponse{}, err
+	}
+	candidateRequest, err := h.createCandidateSimulationRequest(designRequest)
+	if err != nil {
+		return api.DesignResponse{}, err
+	}
+	designedResponse, err := h.runRecommender(ctx, candidateRequest, opts)
+	if err != nil {
+		return api.DesignResponse{}, err
+	}
+	pa := h.engine.PricingAccess()
+	response := api.DesignResponse{
+		WorkerPools:     toDesignedWorkerPools(designedResponse.Recommendation.ScaleUp, designRequest.Zones, pa),
+		UnscheduledPods: designedResponse.UnscheduledPods,
+		ExistingPools: api.DesignComparison{
+			Recommendation:  existingResponse.Recommendation,
+			UnscheduledPods: existingResponse.UnscheduledPods,
+			Cost:            computeScaleUpCost(existingResponse.Recommendation.ScaleUp, pa),
+		},
+		Seed: opts.seed,
+	}
+	for _, wp := range response.WorkerPools {
+		response.Cost += wp.Cost
+	}
+	response.Savings = response.ExistingPools.Cost - response.Cost
+	var partialReasons []string
+	for _, r := range []api.RecommendationResponse{existingResponse, designedResponse} {
+		if r.Partial {
+			partialReasons = append(partialReasons, r.PartialReason)
+		}
+	}
+	response.Partial = len(partialReasons) > 0
+	response.PartialReason = strings.Join(partialReasons, "; ")
+	response.RunTime = fmt.Sprintf("%d millis", time.Since(startTime).Milliseconds())
+	return response, nil
+}
+
+// createCandidateSimulationRequest replaces the node pools of the design request with a pool per candidate instance type
+// in the zones of the request, whose node templates are synthesised from the pricing catalog.
+func (h *Handler) createCandidateSimulationRequest(designRequest api.DesignRequest) (api.SimulationRequest, error) {
+	simRequest := designRequest.SimulationRequest
+	simRequest.ID = designRequest.ID + "-design"
+	simRequest.NodePools = nil
+	simRequest.SyntheticNodeTemplates = nil
+	// the templates of the existing nodes are kept, the request itself must not be changed as it is archived.
+	simRequest.NodeTemplates = cloneNodeTemplates(designRequest.NodeTemplates)
+	for _, instancePricing := range h.findCandidateInstanceTypes(designRequest) {
+		poolName := designedPoolPrefix + strings.ReplaceAll(instancePricing.InstanceType, ".", "-")
+		arch := pricing.InstanceArchitecture(instancePricing.InstanceType)
+		labels := map[string]string{LabelArch: arch, corev1.LabelArchStable: arch}
+		syntheticNodeTemplates, err := h.addSyntheticNodeTemplates(simRequest.NodeTemplates, poolName, instancePricing.InstanceType, designRequest.Zones, labels, nil)
+		if err != nil {
+			return api.SimulationRequest{}, err
+		}
+		simRequest.SyntheticNodeTemplates = append(simRequest.SyntheticNodeTemplates, syntheticNodeTemplates...)
+		simRequest.NodePools = append(simRequest.NodePools, api.NodePool{
+			Name:         poolName,
+			Zones:        sets.New(designRequest.Zones...),
+			Max:          designRequest.MaxNodesPerPool,
+			InstanceType: instancePricing.InstanceType,
+		})
+	}
+	if len(simRequest.NodePools) == 0 {
+		return api.SimulationRequest{}, web.NewHTTPError(http.StatusUnprocessableEntity, fmt.Errorf("no instance type of the pricing catalog matches the filters and fits a pending pod"))
+	}
+	addGenericLabels(simRequest.NodeTemplates)
+	return simRequest, nil
+}
+
+// instanceShape identifies instance types whose synthesised node templates are identical apart from the instance type.
+type instanceShape struct {
+	vCPU   pricing.Float
+	memory pricing.Float
+	arch   string
+}
+
+// findCandidateInstanceTypes returns the instance types of the pricing catalog which match the filters of the design
+// request and whose nodes fit at least one pending pod. Of instance types with the same shape only the cheapest is a
+// candidate, since the others can only lose against it.
+func (h *Handler) findCandidateInstanceTypes(designRequest api.DesignRequest) []pricing.InstancePricing {
+	families := sets.New(designRequest.InstanceFamilies...)
+	architectures := sets.New(designRequest.Architectures...)
+	pendingPodRequests := getPendingPodRequests(designRequest.Pods)
+	cheapestByShape := make(map[instanceShape]pricing.InstancePricing)
+	for _, instancePricing := range h.engine.PricingAccess().ListInstancePricing() {
+		if instancePricing.VCpu <= 0 || instancePricing.Memory <= 0 || instancePricing.EDPPrice.Reserved3Year <= 0 {
+			continue
+		}
+		arch := pricing.InstanceArchitecture(instancePricing.InstanceType)
+		if families.Len() > 0 && !families.Has(pricing.InstanceFamily(instancePricing.InstanceType)) {
+			continue
+		}
+		if architectures.Len() > 0 && !architectures.Has(arch) {
+			continue
+		}
+		nt, err := util.SynthesizeNodeTemplate(instancePricing, "", "", "", nil, nil, h.nodeReservation)
+		if err != nil {
+			continue
+		}
+		allocatable := util.WithSystemOverhead(map[string]gsc.NodeTemplate{"": nt}, designRequest.SystemOverhead)[""].Allocatable
+		if !slices.ContainsFunc(pendingPodRequests, func(requests corev1.ResourceList) bool {
+			return requests.Cpu().Cmp(*allocatable.Cpu()) <= 0 && requests.Memory().Cmp(*allocatable.Memory()) <= 0
+		}) {
+			continue
+		}
+		shape := instanceShape{vCPU: instancePricing.VCpu, memory: instancePricing.Memory, arch: arch}
+		if cheapest, ok := cheapestByShape[shape]; !ok || instancePricing.EDPPrice.Reserved3Year < cheapest.EDPPrice.Reserved3Year {
+			cheapestByShape[shape] = instancePricing
+		}
+	}
+	candidates := make([]pricing.InstancePricing, 0, len(cheapestByShape))
+	for _, instancePricing := range cheapestByShape {
+		candidates = append(candidates, instancePricing)
+	}
+	slices.SortFunc(candidates, func(a, b pricing.InstancePricing) int {
+		return strings.Compare(a.InstanceType, b.InstanceType)
+	})
+	return candidates
+}
+
+// getPendingPodRequests returns the requests of the pods which are neither scheduled nor placed by a DaemonSet.
+func getPendingPodRequests(podInfos []api.PodInfo) []corev1.ResourceList {
+	var pendingPodRequests []corev1.ResourceList
+	for _, p := range podInfos {
+		pod := &corev1.Pod{Spec: p.Spec}
+		pod.OwnerReferences = p.OwnerReferences
+		if pod.Spec.NodeName != "" || util.IsDaemonSetPod(pod) {
+			continue
+		}
+		pendingPodRequests = append(pendingPodRequests, resourcehelper.PodRequests(pod, resourcehelper.PodResourcesOptions{}))
+	}
+	return pendingPodRequests
+}
+
+// toDesignedWorkerPools groups the scale-ups of the candidate pools into worker pools, sorted by name.
+func toDesignedWorkerPools(recommendations []api.ScaleUpRecommendation, zones []string, pa pricing.InstancePricingAccess) []api.DesignedWorkerPool {
+	poolsByName := make(map[string]*api.DesignedWorkerPool)
+	for _, rec := range recommendations {
+		wp, ok := poolsByName[rec.NodePoolName]
+		if !ok {
+			wp = &api.DesignedWorkerPool{
+				Name:         rec.NodePoolName,
+				InstanceType: rec.InstanceType,
+				Architecture: pricing.InstanceArchitecture(rec.InstanceType),
+				Zones:        slices.Clone(zones),
+				NodesPerZone: make(map[string]int32),
+			}
+			poolsByName[rec.NodePoolName] = wp
+		}
+		wp.NodesPerZone[rec.Zone] += rec.IncrementBy
+		wp.Cost += float64(rec.IncrementBy) * pa.Get3YearReservedPricing(rec.InstanceType)
+	}
+	workerPools := make([]api.DesignedWorkerPool, 0, len(poolsByName))
+	for _, wp := range poolsByName {
+		workerPools = append(workerPools, *wp)
+	}
+	slices.SortFunc(workerPools, func(a, b api.DesignedWorkerPool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return workerPools
+}
+
+// computeScaleUpCost returns the price of all nodes of the recommendations.
+func computeScaleUpCost(recommendations []api.ScaleUpRecommendation, pa pricing.InstancePricingAccess) float64 {
+	var cost float64
+	for _, rec := range recommendations {
+		cost += float64(rec.IncrementBy) * pa.Get3YearReservedPricing(rec.InstanceType)
+	}
+	return cost
+}
+
+// normalizeDesignRequest defaults the zones to those of the node pools and the maximum of the designed pools.
+func normalizeDesignRequest(designRequest *api.DesignRequest) {
+	if len(designRequest.Zones) == 0 {
+		zones := sets.New[string]()
+		for _, np := range designRequest.NodePools {
+			zones = zones.Union(np.Zones)
+		}
+		designRequest.Zones = sets.List(zones)
+	}
+	if designRequest.MaxNodesPerPool == 0 {
+		designRequest.MaxNodesPerPool = api.DefaultMaxNodesPerDesignedPool
+	}
+}
+
+// validateDesignRequest checks a normalized design request which is simulated with the given backend.
+func validateDesignRequest(designRequest *api.DesignRequest, backend simulator.Backend) []api.ValidationError {
+	errs := validationErrors(validateSimulationRequest(&designRequest.SimulationRequest, backend))
+	if len(designRequest.Zones) == 0 {
+		errs.add("zones", "at least one zone is required")
+	}
+	for i, zone := range designRequest.Zones {
+		if zone == "" {
+			errs.add(fmt.Sprintf("zones[%d]", i), "must not be empty")
+		}
+	}
+	for i, arch := range designRequest.Architectures {
+		if arch != pricing.ArchitectureAMD64 && arch != pricing.ArchitectureARM64 {
+			errs.add(fmt.Sprintf("architectures[%d]", i), "architecture %q is not supported, expected %s or %s", arch, pricing.ArchitectureAMD64, pricing.ArchitectureARM64)
+		}
+	}
+	if designRequest.MaxNodesPerPool < 0 {
+		errs.add("maxNodesPerPool", "must not be negative")
+	}
+	return errs
+}
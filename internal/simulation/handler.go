@@ -2,18 +2,25 @@ package simulation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	gsc "github.com/elankath/gardener-scaling-common"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"slices"
+	"strconv"
 	"time"
 	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/archive"
 	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/metrics"
 	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/scaler/scorer"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
 	"unmarshall/scaling-recommender/internal/simulation/web"
 	"unmarshall/scaling-recommender/internal/util"
 )
@@ -27,27 +34,270 @@ const (
 
 type Handler struct {
 	engine Engine
+	// baseCtx is the context from which the contexts of asynchronous jobs are derived.
+	baseCtx context.Context
+	jobs    *jobStore
+	// runSlot ensures that only one recommendation runs at a time as all runs share the same virtual cluster.
+	runSlot chan struct{}
+	// responses caches the responses of recommendations computed from snapshots, it is nil if caching is disabled.
+	responses  *responseCache
+	appVersion string
+	backend    simulator.Backend
+	// systemOverhead is the configured overhead of system components per node, nil if it is derived from the snapshot.
+	systemOverhead corev1.ResourceList
+	// nodeReservation are the resources reserved on the nodes of node templates synthesised from the pricing catalog.
+	nodeReservation api.NodeReservation
 }
 
-func NewSimulationHandler(engine Engine) *Handler {
+func NewSimulationHandler(ctx context.Context, engine Engine, appConfig api.AppConfig) *Handler {
 	return &Handler{
-		engine: engine,
+		engine:          engine,
+		baseCtx:         ctx,
+		jobs:            newJobStore(appConfig.JobRetention),
+		runSlot:         make(chan struct{}, 1),
+		responses:       newResponseCache(appConfig.ResponseCacheSize, appConfig.ResponseCacheTTL),
+		appVersion:      appConfig.Version,
+		backend:         simulator.Backend(appConfig.SimulatorBackend),
+		systemOverhead:  appConfig.SystemOverhead,
+		nodeReservation: appConfig.NodeReservation,
 	}
 }
 
-func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
-	defer func() {
-		err := r.Body.Close()
-		if err != nil {
-			slog.Info("error closing request body", "error", err)
+// runOptions tweak a single recommendation run.
+type runOptions struct {
+	// reporter receives progress events while the recommender runs.
+	reporter scaler.ProgressReporter
+	// onStart is invoked once the run has acquired the virtual cluster.
+	onStart func()
+	// podSource is the source of the pods to simulate.
+	podSource api.PodSource
+	// input is the request for which the recommender runs, it is archived together with the results of the run.
+	input any
+	// deadline is the point in time by which the recommendation has to be returned. Zero means no deadline.
+	deadline time.Time
+	// seed overrides the seed of the simulation request if set.
+	seed *int64
+	// bypassCache forces the recommendation to be computed even if a cached response exists. The computed response is cached.
+	bypassCache bool
+	// explain adds the scores of all candidates evaluated per round to the response.
+	explain bool
+	// onCacheLookup is invoked with the outcome of the lookup in the response cache if the response may be cached.
+	onCacheLookup func(hit bool)
+	// scorer scores the candidates of the run. If nil, the scorer of the engine is used.
+	scorer scaler.Scorer
+}
+
+// parseRunOptions reads the run options passed as query parameters.
+func (h *Handler) parseRunOptions(r *http.Request) (runOptions, error) {
+	opts := runOptions{podSource: h.engine.DefaultPodSource()}
+	query := r.URL.Query()
+	deadline, err := parseDeadline(r)
+	if err != nil {
+		return opts, err
+	}
+	opts.deadline = deadline
+	if opts.seed, err = parseSeed(r); err != nil {
+		return opts, err
+	}
+	if opts.explain, err = parseExplain(r); err != nil {
+		return opts, err
+	}
+	if opts.scorer, err = h.parseScorer(r); err != nil {
+		return opts, err
+	}
+	opts.bypassCache = isCacheBypassed(r)
+	if podSource := query.Get("podSource"); podSource != "" {
+		opts.podSource = api.PodSource(podSource)
+	}
+	switch opts.podSource {
+	case api.PodSourceSnapshot:
+	case api.PodSourceTarget:
+		if h.engine.TargetClient() == nil {
+			return opts, fmt.Errorf("pod source %q requires a target cluster but none is configured", opts.podSource)
 		}
-	}()
+	default:
+		return opts, fmt.Errorf("unsupported pod source %q", opts.podSource)
+	}
+	return opts, nil
+}
 
-	// first clean up the virtual cluster
-	if err := h.engine.VirtualControlPlane().FactoryReset(r.Context()); err != nil {
+// parseDeadline computes the deadline of the request from the timeout query parameter, e.g. timeout=90s.
+func parseDeadline(r *http.Request) (time.Time, error) {
+	timeoutParam := r.URL.Query().Get("timeout")
+	if timeoutParam == "" {
+		return time.Time{}, nil
+	}
+	timeout, err := time.ParseDuration(timeoutParam)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timeout %q: %w", timeoutParam, err)
+	}
+	if timeout <= 0 {
+		return time.Time{}, fmt.Errorf("timeout must be positive, got %q", timeoutParam)
+	}
+	return time.Now().Add(timeout), nil
+}
+
+// parseSeed reads the seed query parameter which makes the recommendation reproducible, e.g. seed=42.
+func parseSeed(r *http.Request) (*int64, error) {
+	seedParam := r.URL.Query().Get("seed")
+	if seedParam == "" {
+		return nil, nil
+	}
+	seed, err := strconv.ParseInt(seedParam, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed %q: %w", seedParam, err)
+	}
+	return &seed, nil
+}
+
+// parseExplain reads the explain query parameter which adds the scores of all candidates to the response, e.g. explain=true.
+func parseExplain(r *http.Request) (bool, error) {
+	explainParam := r.URL.Query().Get("explain")
+	if explainParam == "" {
+		return false, nil
+	}
+	explain, err := strconv.ParseBool(explainParam)
+	if err != nil {
+		return false, fmt.Errorf("invalid explain %q: %w", explainParam, err)
+	}
+	return explain, nil
+}
+
+// parseScorer reads the strategy query parameter which overrides the scoring strategy of the engine, e.g. strategy=cost-only.
+func (h *Handler) parseScorer(r *http.Request) (scaler.Scorer, error) {
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		return nil, nil
+	}
+	if !scaler.IsScoringStrategySupported(strategy) {
+		return nil, fmt.Errorf("scoring strategy %q is not supported", strategy)
+	}
+	return scorer.NewFactory(h.engine.PricingAccess()).GetScorer(scaler.ScoringStrategy(strategy))
+}
+
+func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
+	defer closeRequestBody(r)
+
+	clusterSnapshot, err := web.ParseClusterSnapshot(r.Body)
+	if err != nil {
+		slog.Info("error parsing cluster snapshot", "error", err)
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := h.parseRunOptions(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if validationErrs := validateClusterSnapshot(clusterSnapshot, h.engine.PricingAccess()); len(validationErrs) > 0 {
+		web.ValidationErrorResponse(w, validationErrs)
+		return
+	}
+	opts.input = clusterSnapshot
+	h.respond(w, r, opts, func(ctx context.Context, opts runOptions) (api.RecommendationResponse, error) {
+		return h.recommend(ctx, clusterSnapshot, opts)
+	})
+}
+
+// simulate runs the recommender for a SimulationRequest which is posted as is instead of being derived from a cluster snapshot.
+func (h *Handler) simulate(w http.ResponseWriter, r *http.Request) {
+	defer closeRequestBody(r)
+
+	simRequest, err := web.ParseSimulationRequest(r.Body)
+	if err != nil {
+		slog.Info("error parsing simulation request", "error", err)
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = h.normalizeSimulationRequest(simRequest); err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if simRequest.SystemOverhead == nil {
+		simRequest.SystemOverhead = h.systemOverhead
+	}
+	if validationErrs := validateSimulationRequest(simRequest, h.backend); len(validationErrs) > 0 {
+		web.ValidationErrorResponse(w, validationErrs)
+		return
+	}
+	deadline, err := parseDeadline(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	seed, err := parseSeed(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	explain, err := parseExplain(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scorer, err := h.parseScorer(r)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// all inputs are part of the simulation request, the target cluster is never consulted.
+	opts := runOptions{podSource: api.PodSourceSnapshot, deadline: deadline, seed: seed, explain: explain, bypassCache: isCacheBypassed(r), input: simRequest, scorer: scorer}
+	h.respond(w, r, opts, func(ctx context.Context, opts runOptions) (api.RecommendationResponse, error) {
+		return h.runRecommender(ctx, *simRequest, opts)
+	})
+}
+
+// recommendFunc computes a recommendation with the given run options.
+type recommendFunc func(ctx context.Context, opts runOptions) (api.RecommendationResponse, error)
+
+// respond writes the outcome of recommend either as a single JSON response or as a stream of progress events if the caller asked for it.
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, opts runOptions, recommend recommendFunc) {
+	if format, ok := web.GetStreamFormat(r); ok {
+		h.streamRun(w, r, opts, format, recommend)
+		return
+	}
+	opts.onCacheLookup = func(hit bool) {
+		status := cacheMiss
+		if hit {
+			status = cacheHit
+		}
+		w.Header().Set(cacheStatusHeader, status)
+	}
+	response, err := recommend(r.Context(), opts)
+	if err != nil {
+		web.ErrorResponse(w, web.StatusCodeForError(err), err.Error())
+		return
+	}
+	if err = web.WriteJSON(w, http.StatusOK, response); err != nil {
 		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// streamRun runs the recommender and streams its progress to the caller. The stream ends with either a
+// CompletedEvent carrying the RecommendationResponse or an ErrorEvent.
+func (h *Handler) streamRun(w http.ResponseWriter, r *http.Request, opts runOptions, format web.StreamFormat, recommend recommendFunc) {
+	stream, err := web.NewEventStream(w, format)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+	opts.reporter = stream
+	response, err := recommend(r.Context(), opts)
+	if err != nil {
+		stream.Report(api.ProgressEvent{Type: api.ErrorEvent, Time: time.Now(), Error: err.Error()})
 		return
 	}
+	stream.Report(api.ProgressEvent{
+		Type:          api.CompletedEvent,
+		Time:          time.Now(),
+		PodsRemaining: len(response.UnscheduledPods),
+		Response:      &response,
+	})
+}
+
+func (h *Handler) submitJob(w http.ResponseWriter, r *http.Request) {
+	defer closeRequestBody(r)
 
 	clusterSnapshot, err := web.ParseClusterSnapshot(r.Body)
 	if err != nil {
@@ -55,39 +305,316 @@ func (h *Handler) run(w http.ResponseWriter, r *http.Request) {
 		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	simRequest, err := h.createSimulationRequest(r.Context(), clusterSnapshot)
+	opts, err := h.parseRunOptions(r)
 	if err != nil {
-		slog.Error("error creating simulation request", "error", err)
 		web.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if validationErrs := validateClusterSnapshot(clusterSnapshot, h.engine.PricingAccess()); len(validationErrs) > 0 {
+		web.ValidationErrorResponse(w, validationErrs)
+		return
+	}
+	jobCtx, job, err := h.jobs.create(h.baseCtx, clusterSnapshot.ID)
+	if err != nil {
+		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.Info("accepted recommendation job", "jobID", job.ID, "snapshotID", clusterSnapshot.ID)
+	opts.input = clusterSnapshot
+	go h.runJob(jobCtx, job.ID, clusterSnapshot, opts)
+
+	w.Header().Set("Location", apiV1Prefix+"/recommendations/"+job.ID)
+	if err = web.WriteJSON(w, http.StatusAccepted, job); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.get(r.PathValue("id"))
+	if !ok {
+		web.ErrorResponse(w, http.StatusNotFound, errJobNotFound.Error())
+		return
+	}
+	if err := web.WriteJSON(w, http.StatusOK, job); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) cancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errJobNotFound):
+		web.ErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, errJobFinished):
+		web.ErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	slog.Info("cancelled recommendation job", "jobID", job.ID)
+	if err = web.WriteJSON(w, http.StatusAccepted, job); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) runJob(ctx context.Context, jobID string, cs *api.ClusterSnapshot, opts runOptions) {
+	opts.onStart = func() {
+		h.jobs.markRunning(jobID)
+	}
+	response, err := h.recommend(ctx, cs, opts)
+	if err != nil {
+		slog.Error("recommendation job failed", "jobID", jobID, "error", err)
+		h.jobs.complete(jobID, nil, err)
+		return
+	}
+	h.jobs.complete(jobID, &response, nil)
+}
+
+// recommend runs the scale-up recommender for the given cluster snapshot. If the pods are read from the target cluster
+// then the resulting recommendation is applied on it.
+func (h *Handler) recommend(ctx context.Context, cs *api.ClusterSnapshot, opts runOptions) (api.RecommendationResponse, error) {
+	simRequest, err := h.createSimulationRequest(ctx, cs, opts.podSource)
+	if err != nil {
+		slog.Error("error creating simulation request", "error", err)
+		return api.RecommendationResponse{}, web.NewHTTPError(http.StatusBadRequest, err)
+	}
+	return h.runRecommender(ctx, simRequest, opts)
+}
+
+// runRecommender runs the scale-up recommender for the given simulation request. Runs are serialized since they share the virtual cluster.
+// If the deadline of the run expires, the recommendations computed until then are returned as a partial response.
+func (h *Handler) runRecommender(ctx context.Context, simRequest api.SimulationRequest, opts runOptions) (response api.RecommendationResponse, err error) {
+	scorer := opts.scorer
+	if scorer == nil {
+		scorer = h.engine.GetScorer()
+	}
+	strategy := string(scorer.Strategy())
+	defer func() {
+		metrics.RecommendationRequests.WithLabelValues(strategy, resultLabel(err)).Inc()
+	}()
+	if opts.seed != nil {
+		simRequest.Seed = opts.seed
+	}
+	cacheKey, cachedResponse, ok := h.lookupCachedResponse(simRequest, strategy, opts)
+	if ok {
+		return withExplanation(cachedResponse, opts.explain), nil
+	}
+	if simRequest.Seed == nil {
+		// choose a seed so that the response tells how to reproduce the recommendation.
+		seed := time.Now().UnixNano()
+		simRequest.Seed = &seed
+	}
+	runCtx := ctx
+	if !opts.deadline.IsZero() {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithDeadline(ctx, opts.deadline)
+		defer cancel()
+	}
+	if err := h.acquireRunSlot(runCtx); err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return api.RecommendationResponse{
+				Partial:       true,
+				PartialReason: "deadline exceeded while waiting for a previous recommendation to finish",
+				Seed:          simRequest.Seed,
+			}, nil
+		}
+		return api.RecommendationResponse{}, err
+	}
+	defer h.releaseRunSlot()
+	if opts.onStart != nil {
+		opts.onStart()
+	}
+	if opts.reporter == nil {
+		opts.reporter = scaler.NoopProgressReporter{}
+	}
+
+	// first clean up the virtual cluster
+	if vcp := h.engine.VirtualControlPlane(); vcp != nil {
+		if err := vcp.FactoryReset(ctx); err != nil {
+			return api.RecommendationResponse{}, err
+		}
+		// storage objects are not removed by the factory reset.
+		if err := util.DeleteAllVolumes(ctx, vcp.Client(), common.DefaultNamespace); err != nil {
+			return api.RecommendationResponse{}, err
+		}
+	}
 
 	baseLogger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	logger := baseLogger.With("id", simRequest.ID)
-	logger.Info("received simulation request", "request", simRequest.ID)
+	logger.Info("received simulation request", "request", simRequest.ID, "deadline", opts.deadline)
 
+	runWriter := h.startArchiveRun(simRequest, opts.input)
+	defer func() {
+		h.finishArchiveRun(runWriter, response, err)
+	}()
 	recommender := h.engine.RecommenderFactory().GetRecommender(scaler.DefaultScaleUpAlgo)
 	startTime := time.Now()
-	result := recommender.Run(r.Context(), h.engine.GetScorer(), simRequest)
+	result := recommender.Run(runCtx, scorer, simRequest, opts.reporter)
+	h.archiveRunResult(runWriter, result)
 	if result.IsError() {
-		slog.Error("Error in running simulation", result.Err.Error())
-		web.ErrorResponse(w, http.StatusInternalServerError, result.Err.Error())
-		return
+		slog.Error("Error in running simulation", "error", result.Err)
+		return api.RecommendationResponse{}, result.Err
 	}
-	if err = h.applyRecommendation(r.Context(), result.Ok.Recommendation.ScaleUp, simRequest.NodeTemplates); err != nil {
-		slog.Error("Failed in applying recommendation")
-		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
-		return
+	if opts.podSource == api.PodSourceTarget {
+		// the recommendation computed until the deadline is applied as well, hence the deadline does not apply here.
+		if err = h.applyRecommendation(ctx, result.Ok.Recommendation.ScaleUp, simRequest.NodeTemplates); err != nil {
+			slog.Error("Failed in applying recommendation", "error", err)
+			return api.RecommendationResponse{}, err
+		}
 	}
 	runTime := time.Since(startTime)
-	response := api.RecommendationResponse{
+	metrics.RecommendationDuration.WithLabelValues(strategy).Observe(runTime.Seconds())
+	response = api.RecommendationResponse{
 		Recommendation:  result.Ok.Recommendation,
 		UnscheduledPods: result.Ok.UnscheduledPods,
 		RunTime:         fmt.Sprintf("%d millis", runTime.Milliseconds()),
+		Partial:         result.Ok.PartialReason != "",
+		PartialReason:   result.Ok.PartialReason,
+		Seed:            simRequest.Seed,
+		Explanation:     result.Ok.Scores,
 	}
-	if err = web.WriteJSON(w, http.StatusOK, response); err != nil {
+	markSyntheticTemplates(response.Recommendation.ScaleUp, simRequest)
+	if cacheKey != "" {
+		h.responses.add(cacheKey, response)
+	}
+	return withExplanation(response, opts.explain), nil
+}
+
+// withExplanation removes the explanation from response unless explain is set. Cached responses always carry the
+// explanation so that they can serve requests with and without explain.
+func withExplanation(response api.RecommendationResponse, explain bool) api.RecommendationResponse {
+	if !explain {
+		response.Explanation = nil
+	}
+	return response
+}
+
+// lookupCachedResponse returns the cache key of the simulation request and the cached response if one exists. Only
+// recommendations computed from the pods of a snapshot are cached, as the pods of the target cluster change
+// independently of the request. The key is empty if the response must not be cached.
+func (h *Handler) lookupCachedResponse(simRequest api.SimulationRequest, strategy string, opts runOptions) (string, api.RecommendationResponse, bool) {
+	if h.responses == nil || opts.podSource != api.PodSourceSnapshot {
+		return "", api.RecommendationResponse{}, false
+	}
+	cacheKey, err := computeCacheKey(simRequest, strategy, h.engine.PricingAccess().Version(), h.appVersion)
+	if err != nil {
+		slog.Error("failed to compute cache key, response will not be cached", "id", simRequest.ID, "error", err)
+		return "", api.RecommendationResponse{}, false
+	}
+	var (
+		response api.RecommendationResponse
+		hit      bool
+	)
+	if !opts.bypassCache {
+		response, hit = h.responses.get(cacheKey)
+	}
+	if opts.onCacheLookup != nil {
+		opts.onCacheLookup(hit)
+	}
+	return cacheKey, response, hit
+}
+
+// startArchiveRun archives the input of the run. Failing to archive a run does not fail the recommendation.
+func (h *Handler) startArchiveRun(simRequest api.SimulationRequest, input any) *archive.RunWriter {
+	if h.engine.Archive() == nil {
+		return nil
+	}
+	if input == nil {
+		input = simRequest
+	}
+	runWriter, err := h.engine.Archive().StartRun(simRequest.ID, input)
+	if err != nil {
+		slog.Error("failed to archive run", "id", simRequest.ID, "error", err)
+		return nil
+	}
+	return runWriter
+}
+
+func (h *Handler) archiveRunResult(runWriter *archive.RunWriter, result scaler.Result) {
+	if runWriter == nil || result.IsError() {
+		return
+	}
+	if err := runWriter.WriteScores(result.Ok.Scores); err != nil {
+		slog.Error("failed to archive scores", "runID", runWriter.ID(), "error", err)
+	}
+	unscheduledPods := make([]string, 0, len(result.Ok.UnscheduledPods))
+	for _, key := range result.Ok.UnscheduledPods {
+		unscheduledPods = append(unscheduledPods, key.Name)
+	}
+	nodeUtilisation := archive.NodeUtilisation{
+		NodeUtilInfos:   result.Ok.NodeUtilisation,
+		UnscheduledPods: unscheduledPods,
+	}
+	if err := runWriter.WriteNodeUtilisation(nodeUtilisation); err != nil {
+		slog.Error("failed to archive node utilisation", "runID", runWriter.ID(), "error", err)
+	}
+}
+
+func (h *Handler) finishArchiveRun(runWriter *archive.RunWriter, response api.RecommendationResponse, err error) {
+	if runWriter == nil {
+		return
+	}
+	var archivedResponse *api.RecommendationResponse
+	if err == nil {
+		archivedResponse = &response
+	}
+	if archiveErr := runWriter.Finish(archivedResponse, err); archiveErr != nil {
+		slog.Error("failed to archive response", "runID", runWriter.ID(), "error", archiveErr)
+	}
+}
+
+func (h *Handler) listRuns(w http.ResponseWriter, _ *http.Request) {
+	runs, err := h.engine.Archive().ListRuns()
+	if err != nil {
+		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = web.WriteJSON(w, http.StatusOK, runs); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) getRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.engine.Archive().GetRun(r.PathValue("id"))
+	switch {
+	case errors.Is(err, archive.ErrRunNotFound):
+		web.ErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
 		web.ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = web.WriteJSON(w, http.StatusOK, run); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+func resultLabel(err error) string {
+	switch {
+	case err == nil:
+		return metrics.ResultSuccess
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return metrics.ResultCancelled
+	default:
+		return metrics.ResultError
+	}
+}
+
+func (h *Handler) acquireRunSlot(ctx context.Context) error {
+	select {
+	case h.runSlot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Handler) releaseRunSlot() {
+	<-h.runSlot
+}
+
+func closeRequestBody(r *http.Request) {
+	if err := r.Body.Close(); err != nil {
+		slog.Info("error closing request body", "error", err)
 	}
 }
 
@@ -112,37 +639,20 @@ func (h *Handler) applyRecommendation(ctx context.Context, recommendations []api
 	return util.CreateAndUntaintNodes(ctx, targetClient, nodesToCreate)
 }
 
-func (h *Handler) createSimulationRequest(ctx context.Context, cs *gsc.ClusterSnapshot) (simRequest api.SimulationRequest, err error) {
+func (h *Handler) createSimulationRequest(ctx context.Context, cs *api.ClusterSnapshot, podSource api.PodSource) (simRequest api.SimulationRequest, err error) {
 	simRequest.ID = cs.ID
 	for _, pc := range cs.PriorityClasses {
 		simRequest.PriorityClasses = append(simRequest.PriorityClasses, pc.PriorityClass)
 	}
-	var podList corev1.PodList
-	targetClient := h.engine.TargetClient()
-	if err = targetClient.List(ctx, &podList); err != nil {
-		err = fmt.Errorf("[createSimulationRequest] failed to list pods in target cluster: %w", err)
+	if podSource == api.PodSourceSnapshot {
+		simRequest.Pods = getPodInfosFromSnapshot(cs)
+	} else if simRequest.Pods, err = h.getPodInfosFromTargetCluster(ctx); err != nil {
 		return
 	}
-	slices.SortFunc(podList.Items, util.SortPodInfoByCreationTimestamp)
-	for _, p := range podList.Items {
-		if p.Namespace != "kube-system" {
-			pod := api.PodInfo{
-				Name:              p.Name,
-				Labels:            p.Labels,
-				Spec:              p.Spec,
-				NominatedNodeName: p.Status.NominatedNodeName,
-				Count:             1,
-			}
-			simRequest.Pods = append(simRequest.Pods, pod)
-		}
-		if p.Spec.NodeName == "" {
-			slog.Info("[createSimulationRequest] unscheduled pod", "pod", p.Name)
-		}
-	}
 	nodeCountPerPool := deriveNodeCountPerWorkerPool(cs.Nodes)
 	nodePools := make([]api.NodePool, 0, len(cs.WorkerPools))
-	nodeTemplates := cs.AutoscalerConfig.NodeTemplates
-	addGenericLabels(nodeTemplates)
+	// the snapshot is archived as the input of the run, hence templates are added to and changed in a copy.
+	nodeTemplates := cloneNodeTemplates(cs.AutoscalerConfig.NodeTemplates)
 	//nodeTemplates := make(map[string]gsc.NodeTemplate, len(cs.WorkerPools))
 	for _, wp := range cs.WorkerPools {
 		count := nodeCountPerPool[wp.Name]
@@ -156,6 +666,12 @@ func (h *Handler) createSimulationRequest(ctx context.Context, cs *gsc.ClusterSn
 		nodePools = append(nodePools, nodePool)
 		simRequest.NodePools = nodePools
 
+		var syntheticNodeTemplates []string
+		if syntheticNodeTemplates, err = h.addSyntheticNodeTemplates(nodeTemplates, wp.Name, wp.MachineType, wp.Zones, wp.Labels, wp.Taints); err != nil {
+			return
+		}
+		simRequest.SyntheticNodeTemplates = append(simRequest.SyntheticNodeTemplates, syntheticNodeTemplates...)
+
 		//nodeTemplate := FindNodeTemplateForInstanceType(wp.MachineType, cs.AutoscalerConfig.NodeTemplates)
 		//if nodeTemplate == nil {
 		//	err = fmt.Errorf("createSimulationRequest cannot find node template for workerpool %q", wp.Name)
@@ -164,7 +680,13 @@ func (h *Handler) createSimulationRequest(ctx context.Context, cs *gsc.ClusterSn
 		////computeRevisedResourcesForNodeTemplate(nodeTemplate, maxResourceList)
 		//nodeTemplates[wp.MachineType] = *nodeTemplate
 	}
-	simRequest.NodeTemplates = nodeTemplates
+	addGenericLabels(nodeTemplates)
+	simRequest.Volumes = cs.Volumes
+	simRequest.NodeTemplates = withVolumeLimitsFromSnapshot(nodeTemplates, cs)
+	simRequest.SystemOverhead = h.systemOverhead
+	if simRequest.SystemOverhead == nil {
+		simRequest.SystemOverhead = getSystemOverheadFromSnapshot(cs)
+	}
 
 	for _, n := range cs.Nodes {
 		//nodeTemplate, ok := nodeTemplates[n.Labels[common.InstanceTypeLabelKey]]
@@ -189,6 +711,198 @@ func (h *Handler) createSimulationRequest(ctx context.Context, cs *gsc.ClusterSn
 	return
 }
 
+func (h *Handler) getPodInfosFromTargetCluster(ctx context.Context) ([]api.PodInfo, error) {
+	var podList corev1.PodList
+	targetClient := h.engine.TargetClient()
+	if err := targetClient.List(ctx, &podList); err != nil {
+		return nil, fmt.Errorf("[createSimulationRequest] failed to list pods in target cluster: %w", err)
+	}
+	slices.SortFunc(podList.Items, util.SortPodInfoByCreationTimestamp)
+	var podInfos []api.PodInfo
+	for _, p := range podList.Items {
+		if p.Namespace != common.KubeSystemNamespace {
+			pod := api.PodInfo{
+				Name:              p.Name,
+				Labels:            p.Labels,
+				Spec:              p.Spec,
+				NominatedNodeName: p.Status.NominatedNodeName,
+				Count:             1,
+				OwnerReferences:   p.OwnerReferences,
+			}
+			podInfos = append(podInfos, pod)
+		}
+		if p.Spec.NodeName == "" {
+			slog.Info("[createSimulationRequest] unscheduled pod", "pod", p.Name)
+		}
+	}
+	return podInfos, nil
+}
+
+// getPodInfosFromSnapshot converts the pods captured in the cluster snapshot, pods which are being deleted are skipped.
+func getPodInfosFromSnapshot(cs *api.ClusterSnapshot) []api.PodInfo {
+	snapshotPods := slices.Clone(cs.Pods)
+	slices.SortFunc(snapshotPods, func(a, b gsc.PodInfo) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp)
+	})
+	var podInfos []api.PodInfo
+	for _, p := range snapshotPods {
+		if p.Namespace == common.KubeSystemNamespace || !p.DeletionTimestamp.IsZero() {
+			continue
+		}
+		spec := p.Spec
+		if spec.NodeName == "" {
+			spec.NodeName = p.NodeName
+		}
+		if spec.NodeName == "" {
+			slog.Info("[createSimulationRequest] unscheduled pod", "pod", p.Name)
+		}
+		podInfos = append(podInfos, api.PodInfo{
+			Name:              p.Name,
+			Labels:            p.Labels,
+			Spec:              spec,
+			NominatedNodeName: p.NominatedNodeName,
+			Count:             1,
+		})
+	}
+	return podInfos
+}
+
+// getSystemOverheadFromSnapshot returns the maximum requests of the kube-system pods of the snapshot on any node, which are
+// not part of the simulation request. It returns nil if the snapshot has no scheduled kube-system pods.
+func getSystemOverheadFromSnapshot(cs *api.ClusterSnapshot) corev1.ResourceList {
+	var systemPods []corev1.Pod
+	for _, p := range cs.Pods {
+		if p.Namespace != common.KubeSystemNamespace || !p.DeletionTimestamp.IsZero() {
+			continue
+		}
+		pod := corev1.Pod{Spec: p.Spec}
+		if pod.Spec.NodeName == "" {
+			pod.Spec.NodeName = p.NodeName
+		}
+		systemPods = append(systemPods, pod)
+	}
+	systemOverhead := util.GetMaxRequestsPerNode(systemPods)
+	if len(systemOverhead) == 0 {
+		return nil
+	}
+	return systemOverhead
+}
+
+// withVolumeLimitsFromSnapshot returns the node templates with the attach limits of the CSI drivers referenced by the
+// volumes of the snapshot. The snapshot only captures the number of volumes which can be attached to a node, which is
+// applied to every CSI driver. The limit of an instance type is the largest limit of the nodes of that type, templates
+// of instance types without nodes are left without limits.
+func withVolumeLimitsFromSnapshot(nodeTemplates map[string]gsc.NodeTemplate, cs *api.ClusterSnapshot) map[string]gsc.NodeTemplate {
+	drivers := util.GetCSIDriverNames(cs.Volumes)
+	if len(drivers) == 0 {
+		return nodeTemplates
+	}
+	volumeLimits := make(map[string]int)
+	for _, n := range cs.Nodes {
+		instanceType := n.Labels[common.InstanceTypeLabelKey]
+		volumeLimits[instanceType] = max(volumeLimits[instanceType], n.AllocatableVolumes)
+	}
+	revisedNodeTemplates := make(map[string]gsc.NodeTemplate, len(nodeTemplates))
+	for key, nt := range nodeTemplates {
+		if limit := volumeLimits[nt.InstanceType]; limit > 0 {
+			nt.Allocatable = util.WithVolumeLimits(nt.Allocatable, drivers, limit)
+		}
+		revisedNodeTemplates[key] = nt
+	}
+	return revisedNodeTemplates
+}
+
+// normalizeSimulationRequest fills in the defaults of a hand-written simulation request. Node templates may be keyed on the
+// instance type only, in which case a template per node pool and zone is derived from them. Templates which are still
+// missing are synthesised from the pricing catalog, those of instance types unknown to the catalog are left to
+// validateSimulationRequest.
+func (h *Handler) normalizeSimulationRequest(simRequest *api.SimulationRequest) error {
+	if simRequest.ID == "" {
+		id, err := util.GenerateRandomString(4)
+		if err != nil {
+			return err
+		}
+		simRequest.ID = "simulation-" + id
+	}
+	for i := range simRequest.Pods {
+		if simRequest.Pods[i].Count == 0 {
+			simRequest.Pods[i].Count = 1
+		}
+	}
+	if simRequest.NodeTemplates == nil {
+		simRequest.NodeTemplates = make(map[string]gsc.NodeTemplate)
+	}
+	util.AddPoolNodeTemplates(simRequest.NodeTemplates, simRequest.NodePools)
+	simRequest.SyntheticNodeTemplates = nil
+	for _, np := range simRequest.NodePools {
+		syntheticNodeTemplates, err := h.addSyntheticNodeTemplates(simRequest.NodeTemplates, np.Name, np.InstanceType, sets.List(np.Zones), nil, nil)
+		if err != nil {
+			return err
+		}
+		simRequest.SyntheticNodeTemplates = append(simRequest.SyntheticNodeTemplates, syntheticNodeTemplates...)
+	}
+	addGenericLabels(simRequest.NodeTemplates)
+	return nil
+}
+
+// addSyntheticNodeTemplates adds a node template synthesised from the pricing catalog for every zone of the pool without a
+// template and returns the names of the added templates. Nothing is added if the instance type is not in the catalog.
+func (h *Handler) addSyntheticNodeTemplates(nodeTemplates map[string]gsc.NodeTemplate, poolName, instanceType string, zones []string, labels map[string]string, taints []corev1.Taint) ([]string, error) {
+	instancePricing, ok := h.engine.PricingAccess().GetInstancePricing(instanceType)
+	if !ok {
+		return nil, nil
+	}
+	var syntheticNodeTemplates []string
+	for _, zone := range zones {
+		if util.FindNodeTemplate(nodeTemplates, poolName, zone) != nil {
+			continue
+		}
+		nt, err := util.SynthesizeNodeTemplate(instancePricing, poolName, zone, findRegion(nodeTemplates), labels, taints, h.nodeReservation)
+		if err != nil {
+			return nil, fmt.Errorf("cannot synthesise node template for pool %q in zone %q: %w", poolName, zone, err)
+		}
+		nodeTemplates[nt.Name] = nt
+		syntheticNodeTemplates = append(syntheticNodeTemplates, nt.Name)
+	}
+	return syntheticNodeTemplates, nil
+}
+
+// findRegion returns the region of the node templates, all templates of a cluster share the same region. It returns an
+// empty string if no template has a region.
+func findRegion(nodeTemplates map[string]gsc.NodeTemplate) string {
+	for _, nt := range nodeTemplates {
+		if nt.Region != "" {
+			return nt.Region
+		}
+	}
+	return ""
+}
+
+// markSyntheticTemplates marks the recommendations of node pools and zones whose node template was synthesised.
+func markSyntheticTemplates(recommendations []api.ScaleUpRecommendation, simRequest api.SimulationRequest) {
+	if len(simRequest.SyntheticNodeTemplates) == 0 {
+		return
+	}
+	for i, rec := range recommendations {
+		nt := util.FindNodeTemplate(simRequest.NodeTemplates, rec.NodePoolName, rec.Zone)
+		recommendations[i].SyntheticTemplate = nt != nil && slices.Contains(simRequest.SyntheticNodeTemplates, nt.Name)
+	}
+}
+
+// cloneNodeTemplates returns a copy of nodeTemplates whose templates share neither their labels, nor their taints, nor
+// their resources with the original ones. It never returns nil.
+func cloneNodeTemplates(nodeTemplates map[string]gsc.NodeTemplate) map[string]gsc.NodeTemplate {
+	clonedNodeTemplates := make(map[string]gsc.NodeTemplate, len(nodeTemplates))
+	for key, nt := range nodeTemplates {
+		nt.Labels = maps.Clone(nt.Labels)
+		nt.Taints = slices.Clone(nt.Taints)
+		nt.Capacity = nt.Capacity.DeepCopy()
+		nt.Allocatable = nt.Allocatable.DeepCopy()
+		clonedNodeTemplates[key] = nt
+	}
+	return clonedNodeTemplates
+}
+
 func addGenericLabels(nodeTemplates map[string]gsc.NodeTemplate) {
 	for name, nt := range nodeTemplates {
 		ntLabels := nt.Labels
@@ -0,0 +1,61 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/simulation/web"
+)
+
+const healthCheckTimeout = 5 * time.Second
+
+type healthCheck func(ctx context.Context) error
+
+// healthz reports that the recommender is alive. The HTTP server is only started once all components are initialized, so
+// serving the request is all there is to check.
+func (e *engine) healthz(w http.ResponseWriter, r *http.Request) {
+	e.writeHealthStatus(w, r, map[string]healthCheck{})
+}
+
+// readyz reports whether recommendations can be served, i.e. whether the embedded kvcl control plane is reachable. The
+// control plane is only checked if the simulator backend uses it.
+func (e *engine) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]healthCheck{}
+	if e.usesVirtualCluster() {
+		checks["kvcl"] = e.checkVirtualCluster
+	}
+	e.writeHealthStatus(w, r, checks)
+}
+
+func (e *engine) writeHealthStatus(w http.ResponseWriter, r *http.Request, checks map[string]healthCheck) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	status := api.HealthStatus{Status: "ok", Checks: make(map[string]string, len(checks))}
+	statusCode := http.StatusOK
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			status.Checks[name] = err.Error()
+			status.Status = "failed"
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+		status.Checks[name] = "ok"
+	}
+	if err := web.WriteJSON(w, statusCode, status); err != nil {
+		slog.Error("error writing health status", "error", err)
+	}
+}
+
+func (e *engine) checkVirtualCluster(ctx context.Context) error {
+	if err := e.virtualCluster.Client().List(ctx, &corev1.NamespaceList{}, client.Limit(1)); err != nil {
+		return fmt.Errorf("virtual cluster not reachable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+var (
+	errJobNotFound = errors.New("recommendation job not found")
+	errJobFinished = errors.New("recommendation job has already finished")
+)
+
+type job struct {
+	info   api.RecommendationJob
+	cancel context.CancelFunc
+}
+
+// jobStore keeps track of asynchronous recommendation jobs. Finished jobs are retained for the configured
+// retention period after which they are pruned.
+type jobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*job
+	retention time.Duration
+}
+
+func newJobStore(retention time.Duration) *jobStore {
+	return &jobStore{
+		jobs:      make(map[string]*job),
+		retention: retention,
+	}
+}
+
+// create registers a new pending job. The returned context is derived from parent and is cancelled when the job is cancelled.
+func (s *jobStore) create(parent context.Context, snapshotID string) (context.Context, api.RecommendationJob, error) {
+	id, err := util.GenerateRandomString(8)
+	if err != nil {
+		return nil, api.RecommendationJob{}, err
+	}
+	ctx, cancel := context.WithCancel(parent)
+	j := &job{
+		info: api.RecommendationJob{
+			ID:         id,
+			SnapshotID: snapshotID,
+			Status:     api.JobPending,
+			CreatedAt:  time.Now(),
+		},
+		cancel: cancel,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	s.jobs[id] = j
+	return ctx, j.info, nil
+}
+
+func (s *jobStore) get(id string) (api.RecommendationJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	j, ok := s.jobs[id]
+	if !ok {
+		return api.RecommendationJob{}, false
+	}
+	return j.info, true
+}
+
+func (s *jobStore) markRunning(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.info.IsFinished() {
+		return
+	}
+	now := time.Now()
+	j.info.Status = api.JobRunning
+	j.info.StartedAt = &now
+}
+
+// complete records the outcome of a job and releases the resources held by its context.
+func (s *jobStore) complete(id string, response *api.RecommendationResponse, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	defer j.cancel()
+	if j.info.IsFinished() {
+		return
+	}
+	now := time.Now()
+	j.info.FinishedAt = &now
+	switch {
+	case err == nil:
+		j.info.Status = api.JobSucceeded
+		j.info.Result = response
+	case errors.Is(err, context.Canceled):
+		j.info.Status = api.JobCancelled
+		j.info.Error = err.Error()
+	default:
+		j.info.Status = api.JobFailed
+		j.info.Error = err.Error()
+	}
+}
+
+// cancel cancels a pending or running job. The job is marked as cancelled right away, the recommender
+// stops as soon as it observes the cancelled context.
+func (s *jobStore) cancel(id string) (api.RecommendationJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return api.RecommendationJob{}, errJobNotFound
+	}
+	if j.info.IsFinished() {
+		return j.info, errJobFinished
+	}
+	j.cancel()
+	now := time.Now()
+	j.info.Status = api.JobCancelled
+	j.info.FinishedAt = &now
+	j.info.Error = context.Canceled.Error()
+	return j.info, nil
+}
+
+func (s *jobStore) pruneLocked() {
+	now := time.Now()
+	for id, j := range s.jobs {
+		if j.info.FinishedAt != nil && now.Sub(*j.info.FinishedAt) > s.retention {
+			delete(s.jobs, id)
+		}
+	}
+}
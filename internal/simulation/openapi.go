@@ -0,0 +1,159 @@
+package simulation
+
+import (
+	"log/slog"
+	"net/http"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/openapi"
+	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/simulation/web"
+)
+
+// apiV1Prefix is the path prefix of the v1 API.
+const apiV1Prefix = "/v1"
+
+// newOpenAPIDocument describes the v1 API. Schemas are generated from the request and response types.
+func newOpenAPIDocument(version string) *openapi.Document {
+	g := openapi.NewGenerator()
+	responseSchema := g.SchemaOf(api.RecommendationResponse{})
+	jobSchema := g.SchemaOf(api.RecommendationJob{})
+	errorResponses := func(responses map[string]openapi.Response) map[string]openapi.Response {
+		for code, description := range map[string]string{
+			"400": "The request could not be parsed.",
+			"401": "The bearer token is missing or invalid.",
+			"422": "The request is invalid, all validation errors are listed in validationErrors.",
+			"500": "The recommendation failed.",
+		} {
+			responses[code] = openapi.Response{Description: description, Content: openapi.JSONContent(responseSchema)}
+		}
+		return responses
+	}
+	timeoutParameter := openapi.Parameter{Name: "timeout", In: "query", Description: "Deadline of the recommendation, e.g. 90s. Once it expires the recommendations computed so far are returned with partial set.", Schema: &openapi.Schema{Type: "string"}}
+	seedParameter := openapi.Parameter{Name: "seed", In: "query", Description: "Seed of the recommendation, runs with the same input and seed produce the same recommendation.", Schema: &openapi.Schema{Type: "integer", Format: "int64"}}
+	strategyParameter := openapi.Parameter{Name: "strategy", In: "query", Description: "Scoring strategy of the recommendation, overrides the strategy configured at startup.", Schema: &openapi.Schema{Type: "string", Enum: []string{string(scaler.CostOnlyStrategy)}}}
+	explainParameter := openapi.Parameter{Name: "explain", In: "query", Description: "Adds the scores and evaluation times of all candidates per round to the response.", Schema: &openapi.Schema{Type: "boolean"}}
+	podSourceParameter := openapi.Parameter{Name: "podSource", In: "query", Description: "Source of the pods to simulate.", Schema: &openapi.Schema{Type: "string", Enum: []string{string(api.PodSourceTarget), string(api.PodSourceSnapshot)}}}
+	cacheControlParameter := openapi.Parameter{Name: "Cache-Control", In: "header", Description: "no-cache recomputes the recommendation instead of serving a cached response.", Schema: &openapi.Schema{Type: "string"}}
+	streamParameters := []openapi.Parameter{
+		timeoutParameter,
+		seedParameter,
+		cacheControlParameter,
+		explainParameter,
+		strategyParameter,
+		{Name: "stream", In: "query", Description: "Streams progress events instead of returning a single response.", Schema: &openapi.Schema{Type: "string", Enum: []string{string(web.SSEFormat), string(web.NDJSONFormat)}}},
+	}
+	recommendParameters := append([]openapi.Parameter{podSourceParameter}, streamParameters...)
+	jobIDParameter := []openapi.Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+	}
+	cacheHeaders := map[string]openapi.Header{
+		cacheStatusHeader: {Description: "Whether the response was served from the response cache, set if the response may be cached.", Schema: &openapi.Schema{Type: "string", Enum: []string{cacheHit, cacheMiss}}},
+	}
+	snapshotBody := &openapi.RequestBody{Required: true, Content: openapi.JSONContent(g.SchemaOf(api.ClusterSnapshot{}))}
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Scaling Recommender",
+			Description: "Recommends how to scale up the worker pools of a cluster so that all pending pods can be scheduled.",
+			Version:     version,
+		},
+		Paths: map[string]openapi.PathItem{
+			apiV1Prefix + "/recommend": {
+				"post": {
+					OperationID: "recommend",
+					Summary:     "Computes a scale-up recommendation for a cluster snapshot.",
+					Parameters:  recommendParameters,
+					RequestBody: snapshotBody,
+					Responses: errorResponses(map[string]openapi.Response{
+						"200": {Description: "The recommendation.", Headers: cacheHeaders, Content: openapi.JSONContent(responseSchema)},
+					}),
+				},
+			},
+			apiV1Prefix + "/simulate": {
+				"post": {
+					OperationID: "simulate",
+					Summary:     "Computes a scale-up recommendation for a hand-written simulation request.",
+					Parameters:  streamParameters,
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(g.SchemaOf(api.SimulationRequest{}))},
+					Responses: errorResponses(map[string]openapi.Response{
+						"200": {Description: "The recommendation.", Headers: cacheHeaders, Content: openapi.JSONContent(responseSchema)},
+					}),
+				},
+			},
+			apiV1Prefix + "/design": {
+				"post": {
+					OperationID: "design",
+					Summary:     "Proposes the cheapest new worker pools among the instance types of the pricing catalog for the pending pods of a simulation request.",
+					Parameters:  []openapi.Parameter{timeoutParameter, seedParameter, cacheControlParameter, strategyParameter},
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(g.SchemaOf(api.DesignRequest{}))},
+					Responses: errorResponses(map[string]openapi.Response{
+						"200": {Description: "The designed worker pools and the scale-up of the existing node pools.", Content: openapi.JSONContent(g.SchemaOf(api.DesignResponse{}))},
+					}),
+				},
+			},
+			apiV1Prefix + "/recommendations": {
+				"post": {
+					OperationID: "submitRecommendationJob",
+					Summary:     "Submits a cluster snapshot for an asynchronous recommendation.",
+					Parameters:  []openapi.Parameter{podSourceParameter, timeoutParameter, seedParameter, cacheControlParameter, explainParameter, strategyParameter},
+					RequestBody: snapshotBody,
+					Responses: errorResponses(map[string]openapi.Response{
+						"202": {Description: "The accepted job.", Content: openapi.JSONContent(jobSchema)},
+					}),
+				},
+			},
+			apiV1Prefix + "/recommendations/{id}": {
+				"get": {
+					OperationID: "getRecommendationJob",
+					Summary:     "Returns the status and the result of a recommendation job.",
+					Parameters:  jobIDParameter,
+					Responses: map[string]openapi.Response{
+						"200": {Description: "The job.", Content: openapi.JSONContent(jobSchema)},
+						"404": {Description: "The job does not exist.", Content: openapi.JSONContent(responseSchema)},
+					},
+				},
+				"delete": {
+					OperationID: "cancelRecommendationJob",
+					Summary:     "Cancels a pending or running recommendation job.",
+					Parameters:  jobIDParameter,
+					Responses: map[string]openapi.Response{
+						"202": {Description: "The cancelled job.", Content: openapi.JSONContent(jobSchema)},
+						"404": {Description: "The job does not exist.", Content: openapi.JSONContent(responseSchema)},
+						"409": {Description: "The job has already finished.", Content: openapi.JSONContent(responseSchema)},
+					},
+				},
+			},
+			apiV1Prefix + "/runs": {
+				"get": {
+					OperationID: "listRuns",
+					Summary:     "Lists the archived recommender runs, the most recent run first.",
+					Responses: map[string]openapi.Response{
+						"200": {Description: "The archived runs.", Content: openapi.JSONContent(&openapi.Schema{Type: "array", Items: g.SchemaOf(api.RunSummary{})})},
+					},
+				},
+			},
+			apiV1Prefix + "/runs/{id}": {
+				"get": {
+					OperationID: "getRun",
+					Summary:     "Returns an archived run with its input, scores, node utilisation and response.",
+					Parameters:  []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+					Responses: map[string]openapi.Response{
+						"200": {Description: "The archived run.", Content: openapi.JSONContent(g.SchemaOf(api.ArchivedRun{}))},
+						"404": {Description: "The run does not exist.", Content: openapi.JSONContent(responseSchema)},
+					},
+				},
+			},
+		},
+		Components: openapi.Components{Schemas: g.Schemas()},
+	}
+}
+
+func serveOpenAPIDocument(doc *openapi.Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := web.WriteJSON(w, http.StatusOK, doc); err != nil {
+			slog.Error("error writing openapi document", "error", err)
+		}
+	}
+}
@@ -2,32 +2,46 @@ package simulation
 
 import (
 	"context"
+	"fmt"
 	kvclapi "github.com/unmarshall/kvcl/api"
 	kvcl "github.com/unmarshall/kvcl/pkg/control"
 	"k8s.io/client-go/tools/clientcmd"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"log/slog"
 	"net/http"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/archive"
+	"unmarshall/scaling-recommender/internal/metrics"
 	"unmarshall/scaling-recommender/internal/pricing"
 	"unmarshall/scaling-recommender/internal/scaler"
 	"unmarshall/scaling-recommender/internal/scaler/factory"
 	"unmarshall/scaling-recommender/internal/scaler/scorer"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
+	"unmarshall/scaling-recommender/internal/simulation/web"
 )
 
 type Engine interface {
 	Start(ctx context.Context) error
 	Shutdown()
+	// VirtualControlPlane returns the embedded virtual cluster. It returns nil if the simulator backend does not use it.
 	VirtualControlPlane() kvclapi.ControlPlane
 	PricingAccess() pricing.InstancePricingAccess
 	RecommenderFactory() scaler.RecommenderFactory
+	// TargetClient returns the client for the target cluster. It returns nil if no target cluster is configured.
 	TargetClient() client.Client
+	// DefaultPodSource returns the pod source used for requests which do not specify one.
+	DefaultPodSource() api.PodSource
 	GetScorer() scaler.Scorer
+	// Archive returns the archive in which all recommender runs are stored.
+	Archive() *archive.Archive
 }
 
 type engine struct {
 	server             http.Server
+	authenticator      web.Authenticator
 	virtualCluster     kvclapi.ControlPlane
 	pricingAccess      pricing.InstancePricingAccess
 	recommenderFactory scaler.RecommenderFactory
@@ -35,12 +49,13 @@ type engine struct {
 	scorer             scaler.Scorer
 	logger             *slog.Logger
 	targetClient       client.Client
+	archive            *archive.Archive
 }
 
 func NewExecutorEngine(appConfig api.AppConfig, logger *slog.Logger) Engine {
 	return &engine{
 		server: http.Server{
-			Addr: ":8080",
+			Addr: appConfig.ListenAddress,
 		},
 		logger:    logger,
 		appConfig: appConfig,
@@ -48,7 +63,9 @@ func NewExecutorEngine(appConfig api.AppConfig, logger *slog.Logger) Engine {
 }
 
 func (e *engine) Start(ctx context.Context) error {
-	e.startEmbeddedVirtualCluster(ctx)
+	if e.usesVirtualCluster() {
+		e.startEmbeddedVirtualCluster(ctx)
+	}
 	if err := e.initializePricingAccess(); err != nil {
 		return err
 	}
@@ -58,8 +75,18 @@ func (e *engine) Start(ctx context.Context) error {
 	if err := e.createTargetClient(); err != nil {
 		return err
 	}
-	e.recommenderFactory = factory.New(e.virtualCluster, e.appConfig.Version, e.logger)
-	return e.startHTTPServer()
+	if err := e.initializeAuthenticator(); err != nil {
+		return err
+	}
+	if err := e.initializeArchive(); err != nil {
+		return err
+	}
+	schedulerProfile, err := e.loadSchedulerProfile()
+	if err != nil {
+		return err
+	}
+	e.recommenderFactory = factory.New(e.virtualCluster, simulator.Backend(e.appConfig.SimulatorBackend), e.appConfig.SimulationWorkers, schedulerProfile, e.appConfig.Version, e.logger)
+	return e.startHTTPServer(ctx)
 }
 
 func (e *engine) initializeScorer() error {
@@ -72,6 +99,11 @@ func (e *engine) initializeScorer() error {
 	return nil
 }
 
+// usesVirtualCluster reports whether the configured simulator backend requires the embedded virtual cluster.
+func (e *engine) usesVirtualCluster() bool {
+	return e.appConfig.SimulatorBackend == string(simulator.KVCLBackend)
+}
+
 func (e *engine) startEmbeddedVirtualCluster(ctx context.Context) {
 	vCluster := kvcl.NewControlPlane(e.appConfig.BinaryAssetsPath, "/tmp/kvcl-embed.yaml")
 	if err := vCluster.Start(ctx); err != nil {
@@ -82,6 +114,19 @@ func (e *engine) startEmbeddedVirtualCluster(ctx context.Context) {
 	slog.Info("virtual cluster started successfully")
 }
 
+// loadSchedulerProfile loads the scheduler profile configured at startup. It returns nil if none is configured.
+func (e *engine) loadSchedulerProfile() (*schedulerconfig.KubeSchedulerProfile, error) {
+	if e.appConfig.SchedulerConfigPath == "" {
+		return nil, nil
+	}
+	profile, err := simulator.LoadSchedulerProfile(e.appConfig.SchedulerConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	e.logger.Info("scheduling pods with the configured scheduler profile", "path", e.appConfig.SchedulerConfigPath, "schedulerName", profile.SchedulerName)
+	return profile, nil
+}
+
 func (e *engine) initializePricingAccess() error {
 	e.logger.Info("Initializing instance pricing access...")
 	pricingAccess, err := pricing.NewInstancePricingAccess(e.appConfig.Provider)
@@ -92,7 +137,21 @@ func (e *engine) initializePricingAccess() error {
 	return nil
 }
 
+func (e *engine) initializeArchive() error {
+	a, err := archive.New(e.appConfig.ArchiveDir)
+	if err != nil {
+		return err
+	}
+	e.archive = a
+	e.logger.Info("archiving recommender runs", "dir", a.Dir())
+	return nil
+}
+
 func (e *engine) createTargetClient() error {
+	if e.appConfig.TargetKVCLKubeConfigPath == "" {
+		e.logger.Info("no target cluster configured, recommendations will only be computed from the snapshots")
+		return nil
+	}
 	kubeConfigBytes, err := os.ReadFile(e.appConfig.TargetKVCLKubeConfigPath)
 	if err != nil {
 		return err
@@ -113,18 +172,58 @@ func (e *engine) createTargetClient() error {
 	return nil
 }
 
-func (e *engine) startHTTPServer() error {
-	e.server.Handler = e.routes()
-	if err := e.server.ListenAndServe(); err != nil {
-		return err
+func (e *engine) initializeAuthenticator() error {
+	var authenticators web.UnionAuthenticator
+	if e.appConfig.AuthTokenFile != "" {
+		a, err := web.NewStaticTokenAuthenticator(e.appConfig.AuthTokenFile)
+		if err != nil {
+			return err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if e.appConfig.AuthTokenReviewKubeConfigPath != "" {
+		a, err := web.NewTokenReviewAuthenticator(e.appConfig.AuthTokenReviewKubeConfigPath)
+		if err != nil {
+			return err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if len(authenticators) > 0 {
+		e.authenticator = authenticators
 	}
 	return nil
 }
 
+func (e *engine) startHTTPServer(ctx context.Context) error {
+	var handler http.Handler = e.routes(ctx)
+	if e.authenticator != nil {
+		handler = web.RequireBearerToken(e.authenticator, handler)
+	} else {
+		e.logger.Warn("no bearer token authentication configured, all requests will be served")
+	}
+	if e.appConfig.TLSClientCAFile != "" {
+		handler = web.RequireClientCertificate(handler)
+	}
+	e.server.Handler = e.probeRoutes(handler)
+	if e.appConfig.TLSCertFile == "" {
+		e.logger.Info("starting http server", "address", e.server.Addr)
+		return e.server.ListenAndServe()
+	}
+	tlsConfig, err := web.NewServerTLSConfig(e.appConfig.TLSCertFile, e.appConfig.TLSKeyFile, e.appConfig.TLSClientCAFile)
+	if err != nil {
+		return err
+	}
+	e.server.TLSConfig = tlsConfig
+	e.logger.Info("starting https server", "address", e.server.Addr, "mTLS", e.appConfig.TLSClientCAFile != "")
+	return e.server.ListenAndServeTLS("", "")
+}
+
 func (e *engine) Shutdown() {
-	e.logger.Info("shutting down virtual cluster...")
-	if err := e.virtualCluster.Stop(); err != nil {
-		e.logger.Error("failed to stop virtual cluster", "error", err)
+	if e.virtualCluster != nil {
+		e.logger.Info("shutting down virtual cluster...")
+		if err := e.virtualCluster.Stop(); err != nil {
+			e.logger.Error("failed to stop virtual cluster", "error", err)
+		}
 	}
 	if err := e.server.Shutdown(context.Background()); err != nil {
 		slog.Error("error shutting down scenario http server", "error", err)
@@ -155,9 +254,53 @@ func (e *engine) TargetClient() client.Client {
 	return e.targetClient
 }
 
-func (e *engine) routes() *http.ServeMux {
+func (e *engine) DefaultPodSource() api.PodSource {
+	return e.appConfig.PodSource
+}
+
+func (e *engine) Archive() *archive.Archive {
+	return e.archive
+}
+
+func (e *engine) routes(ctx context.Context) *http.ServeMux {
+	mux := http.NewServeMux()
+	h := NewSimulationHandler(ctx, e, e.appConfig)
+	mux.HandleFunc("POST "+apiV1Prefix+"/recommend", h.run)
+	mux.HandleFunc("POST "+apiV1Prefix+"/simulate", h.simulate)
+	mux.HandleFunc("POST "+apiV1Prefix+"/design", h.design)
+	mux.HandleFunc("POST "+apiV1Prefix+"/recommendations", h.submitJob)
+	mux.HandleFunc("GET "+apiV1Prefix+"/recommendations/{id}", h.getJob)
+	mux.HandleFunc("DELETE "+apiV1Prefix+"/recommendations/{id}", h.cancelJob)
+	mux.HandleFunc("GET "+apiV1Prefix+"/runs", h.listRuns)
+	mux.HandleFunc("GET "+apiV1Prefix+"/runs/{id}", h.getRun)
+	mux.HandleFunc("GET "+apiV1Prefix+"/openapi.json", serveOpenAPIDocument(newOpenAPIDocument(e.appConfig.Version)))
+	// the routes served before the v1 API was introduced are kept as deprecated aliases for existing clients. Routes added
+	// since are only served under the v1 prefix.
+	mux.Handle("POST /recommend/", deprecatedAlias(h.run))
+	mux.Handle("POST /simulate", deprecatedAlias(h.simulate))
+	mux.Handle("POST /recommendations", deprecatedAlias(h.submitJob))
+	mux.Handle("GET /recommendations/{id}", deprecatedAlias(h.getJob))
+	mux.Handle("DELETE /recommendations/{id}", deprecatedAlias(h.cancelJob))
+	mux.Handle("GET /metrics", metrics.Handler())
+	return mux
+}
+
+// deprecatedAlias serves an unversioned route with the handler of its v1 route and points clients to the v1 route with the
+// Deprecation and Link headers.
+func deprecatedAlias(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiV1Prefix, strings.TrimSuffix(r.URL.EscapedPath(), "/")))
+		handler(w, r)
+	})
+}
+
+// probeRoutes serves the health probes without authentication so that they can be used as liveness and readiness probes,
+// all other requests are delegated to next.
+func (e *engine) probeRoutes(next http.Handler) *http.ServeMux {
 	mux := http.NewServeMux()
-	h := NewSimulationHandler(e)
-	mux.HandleFunc("POST /recommend/", h.run)
+	mux.HandleFunc("GET /healthz", e.healthz)
+	mux.HandleFunc("GET /readyz", e.readyz)
+	mux.Handle("/", next)
 	return mux
 }
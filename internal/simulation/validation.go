@@ -0,0 +1,175 @@
+package simulation
+
+import (
+	"fmt"
+
+	gsc "github.com/elankath/gardener-scaling-common"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/pricing"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
+	"unmarshall/scaling-recommender/internal/util"
+)
+
+// validationErrors collects the validation errors of a request.
+type validationErrors []api.ValidationError
+
+func (v *validationErrors) add(field, format string, args ...any) {
+	*v = append(*v, api.ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateClusterSnapshot checks a cluster snapshot up front so that an inconsistent snapshot is rejected with all its
+// problems instead of failing somewhere inside the recommender. Missing node templates of worker pools whose machine type
+// is in the pricing catalog are synthesised, hence they are not reported.
+func validateClusterSnapshot(cs *api.ClusterSnapshot, pricingAccess pricing.InstancePricingAccess) []api.ValidationError {
+	var errs validationErrors
+	nodeTemplates := cs.AutoscalerConfig.NodeTemplates
+	nodeCountPerPool := deriveNodeCountPerWorkerPool(cs.Nodes)
+	syntheticInstanceTypes := sets.New[string]()
+	if len(cs.WorkerPools) == 0 {
+		errs.add("WorkerPools", "at least one worker pool is required")
+	}
+	for i, wp := range cs.WorkerPools {
+		field := fmt.Sprintf("WorkerPools[%d]", i)
+		if wp.Name == "" {
+			errs.add(field+".Name", "must not be empty")
+		}
+		if wp.MachineType == "" {
+			errs.add(field+".MachineType", "must not be empty")
+		}
+		if len(wp.Zones) == 0 {
+			errs.add(field+".Zones", "at least one zone is required")
+		}
+		_, inCatalog := pricingAccess.GetInstancePricing(wp.MachineType)
+		for j, zone := range wp.Zones {
+			if util.FindNodeTemplate(nodeTemplates, wp.Name, zone) != nil {
+				continue
+			}
+			if !inCatalog {
+				errs.add(fmt.Sprintf("%s.Zones[%d]", field, j), "no node template found for worker pool %q in zone %q and machine type %q is not in the pricing catalog", wp.Name, zone, wp.MachineType)
+				continue
+			}
+			syntheticInstanceTypes.Insert(wp.MachineType)
+		}
+		if current := nodeCountPerPool[wp.Name]; wp.Maximum < current {
+			errs.add(field+".Maximum", "maximum %d is less than the current number of nodes %d", wp.Maximum, current)
+		}
+	}
+	for i, n := range cs.Nodes {
+		validateNodeTemplateForNode(&errs, fmt.Sprintf("Nodes[%d]", i), ".Labels", n.Name, n.Labels, nodeTemplates, syntheticInstanceTypes)
+	}
+	podNames := make(map[string]int, len(cs.Pods))
+	for i, p := range cs.Pods {
+		key := p.Namespace + "/" + p.Name
+		if first, ok := podNames[key]; ok {
+			errs.add(fmt.Sprintf("Pods[%d].Name", i), "pod %q is a duplicate of Pods[%d]", key, first)
+			continue
+		}
+		podNames[key] = i
+	}
+	validateVolumes(&errs, cs.Volumes)
+	return errs
+}
+
+// validateSimulationRequest checks a normalized simulation request which is simulated with the given backend.
+func validateSimulationRequest(simRequest *api.SimulationRequest, backend simulator.Backend) []api.ValidationError {
+	var errs validationErrors
+	if len(simRequest.NodePools) == 0 {
+		errs.add("nodePools", "at least one node pool is required")
+	}
+	for i, np := range simRequest.NodePools {
+		field := fmt.Sprintf("nodePools[%d]", i)
+		if np.Name == "" {
+			errs.add(field+".name", "must not be empty")
+		}
+		if np.Zones.Len() == 0 {
+			errs.add(field+".zones", "at least one zone is required")
+		}
+		for _, zone := range sets.List(np.Zones) {
+			if util.FindNodeTemplate(simRequest.NodeTemplates, np.Name, zone) == nil {
+				errs.add(field+".zones", "no node template found for node pool %q in zone %q and instance type %q is not in the pricing catalog", np.Name, zone, np.InstanceType)
+			}
+		}
+		if np.Current < 0 || np.Max < np.Current {
+			errs.add(field+".max", "max %d is less than current %d", np.Max, np.Current)
+		}
+	}
+	for i, n := range simRequest.Nodes {
+		validateNodeTemplateForNode(&errs, fmt.Sprintf("nodes[%d]", i), ".labels", n.Name, n.Labels, simRequest.NodeTemplates, nil)
+	}
+	podNames := make(map[string]int, len(simRequest.Pods))
+	for i, p := range simRequest.Pods {
+		field := fmt.Sprintf("pods[%d].name", i)
+		if p.Name == "" {
+			errs.add(field, "must not be empty")
+			continue
+		}
+		if first, ok := podNames[p.Name]; ok {
+			errs.add(field, "pod %q is a duplicate of pods[%d]", p.Name, first)
+			continue
+		}
+		podNames[p.Name] = i
+	}
+	validateVolumes(&errs, simRequest.Volumes)
+	for name, quantity := range simRequest.SystemOverhead {
+		if quantity.Sign() < 0 {
+			errs.add("systemOverhead."+string(name), "must not be negative")
+		}
+	}
+	if simRequest.SchedulerProfile != nil {
+		if !backend.SupportsSchedulerProfiles() {
+			errs.add("schedulerProfile", "not supported by the %s simulator backend", backend)
+		} else if _, err := simulator.ConvertSchedulerProfile(*simRequest.SchedulerProfile); err != nil {
+			errs.add("schedulerProfile", "%v", err)
+		}
+	}
+	return errs
+}
+
+// validateNodeTemplateForNode checks that there is a node template for the instance type of a node, either among
+// nodeTemplates or one which is synthesised for an instance type in syntheticInstanceTypes.
+func validateNodeTemplateForNode(errs *validationErrors, field, labelsField, nodeName string, labels map[string]string, nodeTemplates map[string]gsc.NodeTemplate, syntheticInstanceTypes sets.Set[string]) {
+	instanceType := labels[common.InstanceTypeLabelKey]
+	if instanceType == "" {
+		errs.add(field+labelsField, "node %q has no %s label", nodeName, common.InstanceTypeLabelKey)
+		return
+	}
+	if util.FindNodeTemplateForInstanceType(instanceType, nodeTemplates) == nil && !syntheticInstanceTypes.Has(instanceType) {
+		errs.add(field, "no node template found for instance type %q of node %q", instanceType, nodeName)
+	}
+}
+
+// validateVolumes checks that the storage objects can be loaded into the simulator. Claims are moved to the namespace of
+// the simulated pods, hence their names have to be unique across all namespaces.
+func validateVolumes(errs *validationErrors, volumes api.Volumes) {
+	validateUniqueNames(errs, "persistentVolumes", lo.Map(volumes.PersistentVolumes, func(pv corev1.PersistentVolume, _ int) string {
+		return pv.Name
+	}))
+	validateUniqueNames(errs, "persistentVolumeClaims", lo.Map(volumes.PersistentVolumeClaims, func(pvc corev1.PersistentVolumeClaim, _ int) string {
+		return pvc.Name
+	}))
+	validateUniqueNames(errs, "storageClasses", lo.Map(volumes.StorageClasses, func(sc storagev1.StorageClass, _ int) string {
+		return sc.Name
+	}))
+}
+
+func validateUniqueNames(errs *validationErrors, field string, names []string) {
+	indexByName := make(map[string]int, len(names))
+	for i, name := range names {
+		nameField := fmt.Sprintf("%s[%d].metadata.name", field, i)
+		if name == "" {
+			errs.add(nameField, "must not be empty")
+			continue
+		}
+		if first, ok := indexByName[name]; ok {
+			errs.add(nameField, "%q is a duplicate of %s[%d]", name, field, first)
+			continue
+		}
+		indexByName[name] = i
+	}
+}
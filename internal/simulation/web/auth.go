@@ -0,0 +1,173 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tokenReviewCacheTTL is the duration for which the outcome of a TokenReview is cached.
+const tokenReviewCacheTTL = time.Minute
+
+// Authenticator verifies bearer tokens presented by callers.
+type Authenticator interface {
+	// Authenticate returns true if the token is valid.
+	Authenticate(ctx context.Context, token string) (bool, error)
+}
+
+// NewStaticTokenAuthenticator creates an Authenticator which accepts the tokens listed in the file at tokenFilePath.
+// The file contains one token per line, empty lines and lines starting with '#' are ignored.
+func NewStaticTokenAuthenticator(tokenFilePath string) (Authenticator, error) {
+	file, err := os.Open(tokenFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token file %s does not contain any token", tokenFilePath)
+	}
+	return staticTokenAuthenticator(tokens), nil
+}
+
+type staticTokenAuthenticator []string
+
+func (s staticTokenAuthenticator) Authenticate(_ context.Context, token string) (bool, error) {
+	for _, t := range s {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NewTokenReviewAuthenticator creates an Authenticator which validates tokens by creating a TokenReview against
+// the API server identified by the kubeconfig at kubeConfigPath.
+func NewTokenReviewAuthenticator(kubeConfigPath string) (Authenticator, error) {
+	kubeConfigBytes, err := os.ReadFile(kubeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig, err := clientcmd.NewClientConfigFromBytes(kubeConfigBytes)
+	if err != nil {
+		return nil, err
+	}
+	restCfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cl, err := client.New(restCfg, client.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &tokenReviewAuthenticator{
+		client: cl,
+		cache:  make(map[string]tokenReviewResult),
+	}, nil
+}
+
+type tokenReviewResult struct {
+	authenticated bool
+	expiresAt     time.Time
+}
+
+type tokenReviewAuthenticator struct {
+	client client.Client
+	mu     sync.Mutex
+	cache  map[string]tokenReviewResult
+}
+
+func (t *tokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (bool, error) {
+	if authenticated, ok := t.getCachedResult(token); ok {
+		return authenticated, nil
+	}
+	tokenReview := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := t.client.Create(ctx, tokenReview); err != nil {
+		return false, fmt.Errorf("failed to create token review: %w", err)
+	}
+	authenticated := tokenReview.Status.Authenticated
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cache[token] = tokenReviewResult{authenticated: authenticated, expiresAt: time.Now().Add(tokenReviewCacheTTL)}
+	return authenticated, nil
+}
+
+func (t *tokenReviewAuthenticator) getCachedResult(token string) (bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for k, v := range t.cache {
+		if now.After(v.expiresAt) {
+			delete(t.cache, k)
+		}
+	}
+	result, ok := t.cache[token]
+	return result.authenticated, ok
+}
+
+// UnionAuthenticator accepts a token if any of its authenticators accepts it.
+type UnionAuthenticator []Authenticator
+
+func (u UnionAuthenticator) Authenticate(ctx context.Context, token string) (bool, error) {
+	var errs error
+	for _, a := range u {
+		authenticated, err := a.Authenticate(ctx, token)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if authenticated {
+			return true, nil
+		}
+	}
+	return false, errs
+}
+
+// RequireBearerToken wraps next so that only requests carrying a bearer token accepted by authenticator are served.
+func RequireBearerToken(authenticator Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			ErrorResponse(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		authenticated, err := authenticator.Authenticate(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			slog.Error("error authenticating request", "error", err)
+		}
+		if !authenticated {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			ErrorResponse(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
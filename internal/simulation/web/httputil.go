@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/elankath/gardener-scaling-common"
 	"io"
 	"log/slog"
 	"net/http"
@@ -12,9 +11,51 @@ import (
 	"unmarshall/scaling-recommender/api"
 )
 
-func ParseClusterSnapshot(reqBody io.ReadCloser) (*gsc.ClusterSnapshot, error) {
-	clusterSnapshot := &gsc.ClusterSnapshot{}
-	err := json.NewDecoder(reqBody).Decode(clusterSnapshot)
+// HTTPError is an error which carries the HTTP status code that should be sent to the caller.
+type HTTPError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// NewHTTPError wraps err into an HTTPError with the given status code.
+func NewHTTPError(statusCode int, err error) error {
+	return &HTTPError{StatusCode: statusCode, Err: err}
+}
+
+// StatusCodeForError returns the HTTP status code carried by err. If err is not an HTTPError then http.StatusInternalServerError is returned.
+func StatusCodeForError(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+func ParseClusterSnapshot(reqBody io.ReadCloser) (*api.ClusterSnapshot, error) {
+	return parseJSON[api.ClusterSnapshot](reqBody)
+}
+
+// ParseSimulationRequest decodes an api.SimulationRequest from the request body.
+func ParseSimulationRequest(reqBody io.ReadCloser) (*api.SimulationRequest, error) {
+	return parseJSON[api.SimulationRequest](reqBody)
+}
+
+// ParseDesignRequest decodes an api.DesignRequest from the request body.
+func ParseDesignRequest(reqBody io.ReadCloser) (*api.DesignRequest, error) {
+	return parseJSON[api.DesignRequest](reqBody)
+}
+
+func parseJSON[T any](reqBody io.ReadCloser) (*T, error) {
+	target := new(T)
+	err := json.NewDecoder(reqBody).Decode(target)
 	if err != nil {
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
@@ -35,10 +76,10 @@ func ParseClusterSnapshot(reqBody io.ReadCloser) (*gsc.ClusterSnapshot, error) {
 			return nil, err
 		}
 	}
-	return clusterSnapshot, nil
+	return target, nil
 }
 
-func WriteJSON(w http.ResponseWriter, statusCode int, data api.RecommendationResponse) error {
+func WriteJSON(w http.ResponseWriter, statusCode int, data any) error {
 	jsonBytes, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		return err
@@ -59,3 +100,14 @@ func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 	}
 }
+
+// ValidationErrorResponse rejects a request with 422 Unprocessable Entity, listing all validation errors.
+func ValidationErrorResponse(w http.ResponseWriter, validationErrs []api.ValidationError) {
+	response := api.RecommendationResponse{
+		Error:            fmt.Sprintf("request is invalid, found %d validation error(s)", len(validationErrs)),
+		ValidationErrors: validationErrs,
+	}
+	if err := WriteJSON(w, http.StatusUnprocessableEntity, response); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/scaler"
+)
+
+// StreamFormat is the wire format used to stream progress events to the caller.
+type StreamFormat string
+
+const (
+	// SSEFormat streams progress events as Server-Sent Events.
+	SSEFormat StreamFormat = "sse"
+	// NDJSONFormat streams progress events as newline delimited JSON.
+	NDJSONFormat StreamFormat = "ndjson"
+)
+
+const (
+	sseContentType    = "text/event-stream"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// GetStreamFormat returns the stream format requested either via the `stream` query parameter or the Accept header.
+// The second return value is false if the caller has not asked for a stream.
+func GetStreamFormat(r *http.Request) (StreamFormat, bool) {
+	switch StreamFormat(r.URL.Query().Get("stream")) {
+	case SSEFormat:
+		return SSEFormat, true
+	case NDJSONFormat:
+		return NDJSONFormat, true
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case sseContentType:
+			return SSEFormat, true
+		case ndjsonContentType:
+			return NDJSONFormat, true
+		}
+	}
+	return "", false
+}
+
+// EventStream writes progress events to the response as soon as they are reported. It implements scaler.ProgressReporter.
+type EventStream struct {
+	mu     sync.Mutex
+	w      scaler.LogWriterFlusher
+	format StreamFormat
+}
+
+// NewEventStream writes the response headers for the given format and returns an EventStream.
+func NewEventStream(w http.ResponseWriter, format StreamFormat) (*EventStream, error) {
+	lwf, ok := w.(scaler.LogWriterFlusher)
+	if !ok {
+		return nil, errors.New("response writer does not support streaming")
+	}
+	contentType := ndjsonContentType
+	if format == SSEFormat {
+		contentType = sseContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	lwf.Flush()
+	return &EventStream{w: lwf, format: format}, nil
+}
+
+func (s *EventStream) Report(event api.ProgressEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("error marshalling progress event", "error", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.format == SSEFormat {
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s\n", data)
+	}
+	if err != nil {
+		slog.Error("error writing progress event", "error", err)
+		return
+	}
+	s.w.Flush()
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves the serving certificate from a certificate and key file and transparently reloads
+// it once either of the files changes, allowing certificates to be rotated without restarting the server.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewCertificateReloader loads the certificate and key from the given files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	c := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate is meant to be used as tls.Config.GetCertificate.
+func (c *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		slog.Error("cannot stat serving certificate files, continuing with loaded certificate", "error", err)
+	} else if modTime.After(c.getModTime()) {
+		if err = c.reload(); err != nil {
+			slog.Error("failed to reload serving certificate, continuing with loaded certificate", "error", err)
+		} else {
+			slog.Info("reloaded serving certificate", "certFile", c.certFile)
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *CertificateReloader) reload() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *CertificateReloader) getModTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.modTime
+}
+
+func (c *CertificateReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// NewServerTLSConfig creates the TLS configuration for the HTTP server. If clientCAFile is not empty then
+// certificates presented by clients have to be signed by one of the CAs in that file. Clients may connect without a
+// certificate so that probes can be served, RequireClientCertificate rejects their other requests.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return tlsConfig, nil
+	}
+	caBytes, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("client CA file %s does not contain any PEM encoded certificate", clientCAFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// RequireClientCertificate wraps next so that only requests over a connection with a verified client certificate are
+// served.
+func RequireClientCertificate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			ErrorResponse(w, http.StatusUnauthorized, "missing client certificate")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
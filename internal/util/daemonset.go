@@ -0,0 +1,98 @@
+package util
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+)
+
+// daemonSetTemplateGenerationLabelKey is set on all pods created by the DaemonSet controller. It identifies DaemonSet pods
+// in cluster snapshots, which do not record the owner references of pods.
+const daemonSetTemplateGenerationLabelKey = "pod-template-generation"
+
+// IsDaemonSetPod reports whether pod is owned by a DaemonSet or carries the label of the DaemonSet controller.
+func IsDaemonSetPod(pod *corev1.Pod) bool {
+	return isOwnedByDaemonSet(pod) || metav1.HasLabel(pod.ObjectMeta, daemonSetTemplateGenerationLabelKey)
+}
+
+// GetDaemonSetPods returns a pod per DaemonSet which has pods among pods, with the node binding of the DaemonSet controller
+// removed. If a DaemonSet has pods of several revisions, the last pod is returned.
+func GetDaemonSetPods(pods []*corev1.Pod) []*corev1.Pod {
+	daemonSetPods := make(map[string]*corev1.Pod)
+	for _, pod := range pods {
+		if IsDaemonSetPod(pod) {
+			daemonSetPods[daemonSetKey(pod)] = pod
+		}
+	}
+	keys := maps.Keys(daemonSetPods)
+	slices.Sort(keys)
+	templatePods := make([]*corev1.Pod, 0, len(keys))
+	for _, key := range keys {
+		templatePods = append(templatePods, toDaemonSetTemplatePod(daemonSetPods[key]))
+	}
+	return templatePods
+}
+
+// ShouldRunDaemonSetPod reports whether the DaemonSet controller would create the DaemonSet pod on node, i.e. whether pod
+// selects node and tolerates all of its NoSchedule and NoExecute taints.
+func ShouldRunDaemonSetPod(pod *corev1.Pod, node *corev1.Node) bool {
+	if match, err := nodeaffinity.GetRequiredNodeAffinity(pod).Match(node); err != nil || !match {
+		return false
+	}
+	_, untolerated := corev1helpers.FindMatchingUntoleratedTaint(node.Spec.Taints, pod.Spec.Tolerations, func(taint *corev1.Taint) bool {
+		return taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute
+	})
+	return !untolerated
+}
+
+// daemonSetKey identifies the DaemonSet of pod by the name of its owner. Pods without owner references are identified by
+// their labels without the ones which differ between revisions.
+func daemonSetKey(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.APIVersion == daemonSetGVK.GroupVersion().String() && owner.Kind == daemonSetGVK.Kind {
+			return owner.Name
+		}
+	}
+	labelKeys := maps.Keys(pod.Labels)
+	slices.Sort(labelKeys)
+	var key []string
+	for _, labelKey := range labelKeys {
+		if labelKey != daemonSetTemplateGenerationLabelKey && labelKey != appsv1.ControllerRevisionHashLabelKey {
+			key = append(key, labelKey+"="+pod.Labels[labelKey])
+		}
+	}
+	return strings.Join(key, ",")
+}
+
+// toDaemonSetTemplatePod returns an unbound copy of pod without the node name requirement which the DaemonSet controller
+// adds to the required node affinity of every pod.
+func toDaemonSetTemplatePod(pod *corev1.Pod) *corev1.Pod {
+	podCopy := pod.DeepCopy()
+	podCopy.Spec.NodeName = ""
+	podCopy.Status = corev1.PodStatus{}
+	affinity := podCopy.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return podCopy
+	}
+	var terms []corev1.NodeSelectorTerm
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		term.MatchFields = slices.DeleteFunc(term.MatchFields, func(requirement corev1.NodeSelectorRequirement) bool {
+			return requirement.Key == metav1.ObjectNameField
+		})
+		if len(term.MatchExpressions) > 0 || len(term.MatchFields) > 0 {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = nil
+	} else {
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = terms
+	}
+	return podCopy
+}
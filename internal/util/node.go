@@ -13,6 +13,7 @@ import (
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -156,11 +157,35 @@ func FindNodeTemplateForInstanceType(instanceType string, csNodeTemplates map[st
 	return nil
 }
 
-func ConstructNodeForSimRun(nodeTemplate gsc.NodeTemplate, poolName, zone string, runRef lo.Tuple2[string, string]) (*corev1.Node, error) {
-	nodeNamePrefix, err := GenerateRandomString(4)
-	if err != nil {
-		return nil, err
+// AddPoolNodeTemplates adds a template for every node pool and zone which has none. It is derived from the template keyed
+// on the instance type of the pool, pools without such a template are skipped.
+func AddPoolNodeTemplates(nodeTemplates map[string]gsc.NodeTemplate, nodePools []api.NodePool) {
+	for _, np := range nodePools {
+		for _, zone := range sets.List(np.Zones) {
+			if FindNodeTemplate(nodeTemplates, np.Name, zone) != nil {
+				continue
+			}
+			nt, ok := nodeTemplates[np.InstanceType]
+			if !ok {
+				continue
+			}
+			poolTemplate := nt
+			poolTemplate.Name = fmt.Sprintf("%s-%s", np.Name, zone)
+			poolTemplate.InstanceType = np.InstanceType
+			poolTemplate.Zone = zone
+			poolTemplate.Labels = make(map[string]string, len(nt.Labels)+1)
+			for k, v := range nt.Labels {
+				poolTemplate.Labels[k] = v
+			}
+			poolTemplate.Labels[common.WorkerPoolLabelKey] = np.Name
+			nodeTemplates[poolTemplate.Name] = poolTemplate
+		}
 	}
+}
+
+// ConstructNodeForSimRun creates the node for a simulation run of the given pool and zone. The node name is derived from
+// nodeNamePrefix so that callers control whether node names are reproducible.
+func ConstructNodeForSimRun(nodeTemplate gsc.NodeTemplate, nodeNamePrefix, poolName, zone string, runRef lo.Tuple2[string, string]) *corev1.Node {
 	nodeName := nodeNamePrefix + "-" + poolName + "-sr-" + runRef.B
 	labels := make(map[string]string, len(nodeTemplate.Labels))
 	for k, v := range nodeTemplate.Labels {
@@ -184,7 +209,7 @@ func ConstructNodeForSimRun(nodeTemplate gsc.NodeTemplate, poolName, zone string
 		{Key: runRef.A, Value: runRef.B, Effect: corev1.TaintEffectNoSchedule},
 	}
 	taints = append(taints, nodeTemplate.Taints...)
-	return doConstructNodeFromNodeTemplate(nodeTemplate, nodeName, labels, taints), nil
+	return doConstructNodeFromNodeTemplate(nodeTemplate, nodeName, labels, taints)
 }
 
 func ConstructNodeFromNodeTemplate(nodeTemplate gsc.NodeTemplate, zone, nodeName string) (*corev1.Node, error) {
@@ -221,6 +246,31 @@ func doConstructNodeFromNodeTemplate(nodeTemplate gsc.NodeTemplate, newNodeName
 	}
 }
 
+// WithSystemOverhead returns copies of the node templates whose allocatable is reduced by the resources reserved for system
+// components on every node. Allocatable resources never drop below zero.
+func WithSystemOverhead(nodeTemplates map[string]gsc.NodeTemplate, systemOverhead corev1.ResourceList) map[string]gsc.NodeTemplate {
+	if len(systemOverhead) == 0 {
+		return nodeTemplates
+	}
+	revisedNodeTemplates := make(map[string]gsc.NodeTemplate, len(nodeTemplates))
+	for key, nt := range nodeTemplates {
+		nt.Allocatable = nt.Allocatable.DeepCopy()
+		for name, overhead := range systemOverhead {
+			allocatable, ok := nt.Allocatable[name]
+			if !ok {
+				continue
+			}
+			allocatable.Sub(overhead)
+			if allocatable.Sign() < 0 {
+				allocatable.Set(0)
+			}
+			nt.Allocatable[name] = allocatable
+		}
+		revisedNodeTemplates[key] = nt
+	}
+	return revisedNodeTemplates
+}
+
 func GetInstanceType(labels map[string]string) string {
 	return labels[common.InstanceTypeLabelKey]
 }
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	gsc "github.com/elankath/gardener-scaling-common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/common"
+	"unmarshall/scaling-recommender/internal/pricing"
+)
+
+// defaultMaxPods is the default maximum number of pods per node of the kubelet.
+const defaultMaxPods = 110
+
+// evictionHardMemory is the default hard eviction threshold of the kubelet for the available memory of a node.
+var evictionHardMemory = resource.MustParse("100Mi")
+
+// reservationTier reserves fraction of the part of the capacity between the limit of the previous tier and limit.
+type reservationTier struct {
+	limit    float64
+	fraction float64
+}
+
+// cpuReservationTiers (in cores) and memoryReservationTiers (in GiB) are the tiers of the kube-reserved formula of GKE.
+var (
+	cpuReservationTiers    = []reservationTier{{1, 0.06}, {2, 0.01}, {4, 0.005}, {math.Inf(1), 0.0025}}
+	memoryReservationTiers = []reservationTier{{4, 0.25}, {8, 0.2}, {16, 0.1}, {128, 0.06}, {math.Inf(1), 0.02}}
+)
+
+// SynthesizeNodeTemplate returns a node template for the nodes of poolName in zone whose instance type is described by
+// instancePricing. The capacity is taken from the vCPUs and memory of the pricing catalog, the allocatable is what is left
+// of the capacity after reservation.
+func SynthesizeNodeTemplate(instancePricing pricing.InstancePricing, poolName, zone, region string, labels map[string]string, taints []corev1.Taint, reservation api.NodeReservation) (gsc.NodeTemplate, error) {
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(float64(instancePricing.VCpu)*1000), resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(int64(float64(instancePricing.Memory)*(1<<30)), resource.BinarySI),
+		corev1.ResourcePods:   *resource.NewQuantity(defaultMaxPods, resource.DecimalSI),
+	}
+	reserved, err := ComputeReservedResources(capacity, reservation)
+	if err != nil {
+		return gsc.NodeTemplate{}, err
+	}
+	allocatable := capacity.DeepCopy()
+	for name, quantity := range reserved {
+		allocatableQuantity, ok := allocatable[name]
+		if !ok {
+			continue
+		}
+		allocatableQuantity.Sub(quantity)
+		if allocatableQuantity.Sign() < 0 {
+			allocatableQuantity.Set(0)
+		}
+		allocatable[name] = allocatableQuantity
+	}
+	templateLabels := maps.Clone(labels)
+	if templateLabels == nil {
+		templateLabels = make(map[string]string)
+	}
+	templateLabels[common.WorkerPoolLabelKey] = poolName
+	return gsc.NodeTemplate{
+		Name:         fmt.Sprintf("%s-%s", poolName, zone),
+		InstanceType: instancePricing.InstanceType,
+		Region:       region,
+		Zone:         zone,
+		Capacity:     capacity,
+		Allocatable:  allocatable,
+		Labels:       templateLabels,
+		Taints:       slices.Clone(taints),
+	}, nil
+}
+
+// ComputeReservedResources returns the resources of a node with the given capacity which are reserved for the kubelet and
+// the operating system. CPU and memory which are not part of reservation are reserved with the tiered kube-reserved
+// formula of GKE, memory additionally includes the hard eviction threshold of the kubelet.
+func ComputeReservedResources(capacity corev1.ResourceList, reservation api.NodeReservation) (corev1.ResourceList, error) {
+	memoryInGiB := float64(capacity.Memory().Value()) / (1 << 30)
+	reservedMemory := *resource.NewQuantity(int64(math.Round(tieredReservation(memoryInGiB, memoryReservationTiers)*(1<<30))), resource.BinarySI)
+	reservedMemory.Add(evictionHardMemory)
+	reserved := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(math.Round(tieredReservation(capacity.Cpu().AsApproximateFloat64(), cpuReservationTiers)*1000)), resource.DecimalSI),
+		corev1.ResourceMemory: reservedMemory,
+	}
+	for name, value := range reservation {
+		quantity, err := parseReservedQuantity(name, value, capacity[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid node reservation for %s: %w", name, err)
+		}
+		reserved[name] = quantity
+	}
+	return reserved, nil
+}
+
+// ParseNodeReservation parses a comma separated list of resource name and quantity or percentage pairs, e.g.
+// cpu=80m,memory=10%.
+func ParseNodeReservation(nodeReservation string) (api.NodeReservation, error) {
+	if nodeReservation == "" {
+		return nil, nil
+	}
+	reservation := make(api.NodeReservation)
+	for _, pair := range strings.Split(nodeReservation, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid node reservation %q, expected <resource>=<quantity> or <resource>=<percentage>%%", pair)
+		}
+		if _, err := parseReservedQuantity(corev1.ResourceName(name), value, resource.Quantity{}); err != nil {
+			return nil, fmt.Errorf("invalid node reservation for %s: %w", name, err)
+		}
+		reservation[corev1.ResourceName(name)] = value
+	}
+	return reservation, nil
+}
+
+// parseReservedQuantity parses a quantity or a percentage of capacity. Percentages of CPU are rounded to millicores, those
+// of other resources to whole units.
+func parseReservedQuantity(name corev1.ResourceName, value string, capacity resource.Quantity) (resource.Quantity, error) {
+	if percentage, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := strconv.ParseFloat(percentage, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return resource.Quantity{}, fmt.Errorf("percentage %q must be between 0%% and 100%%", value)
+		}
+		if name == corev1.ResourceCPU {
+			return *resource.NewMilliQuantity(int64(math.Round(float64(capacity.MilliValue())*percent/100)), resource.DecimalSI), nil
+		}
+		return *resource.NewQuantity(int64(math.Round(float64(capacity.Value())*percent/100)), capacity.Format), nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+	if quantity.Sign() < 0 {
+		return resource.Quantity{}, fmt.Errorf("quantity %q must not be negative", value)
+	}
+	return quantity, nil
+}
+
+// tieredReservation returns the amount reserved of capacity by the tiers.
+func tieredReservation(capacity float64, tiers []reservationTier) float64 {
+	var reserved, lowerLimit float64
+	for _, tier := range tiers {
+		if capacity <= lowerLimit {
+			break
+		}
+		reserved += (min(capacity, tier.limit) - lowerLimit) * tier.fraction
+		lowerLimit = tier.limit
+	}
+	return reserved
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"slices"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"unmarshall/scaling-recommender/api"
 
@@ -78,6 +79,7 @@ func ConstructPodsFromPodInfos(podInfos []api.PodInfo, sortOrder string) []*core
 			Labels(podInfo.Labels).
 			Spec(podInfo.Spec).
 			NominatedNodeName(podInfo.NominatedNodeName).
+			OwnerReferences(podInfo.OwnerReferences).
 			Count(podInfo.Count)
 		pods = append(pods, podBuilder.Build()...)
 	}
@@ -85,6 +87,8 @@ func ConstructPodsFromPodInfos(podInfos []api.PodInfo, sortOrder string) []*core
 	return pods
 }
 
+// SplitScheduledAndUnscheduledPods splits pods into the pods which need to be scheduled and all others. Pending DaemonSet
+// pods have to be removed by the caller, they are bound to an existing node by the DaemonSet controller.
 func SplitScheduledAndUnscheduledPods(pods []*corev1.Pod) (unscheduledPods []*corev1.Pod, scheduledPods []*corev1.Pod) {
 	for _, pod := range pods {
 		if isUnscheduled(pod) {
@@ -99,7 +103,7 @@ func SplitScheduledAndUnscheduledPods(pods []*corev1.Pod) (unscheduledPods []*co
 func isUnscheduled(pod *corev1.Pod) bool {
 	return !isScheduled(pod) &&
 		!isPreempting(pod) &&
-		!isOwnedByDaemonSet(pod)
+		!IsDaemonSetPod(pod)
 }
 
 func isScheduled(pod *corev1.Pod) bool {
@@ -110,10 +114,10 @@ func isPreempting(pod *corev1.Pod) bool {
 	return pod.Status.NominatedNodeName != ""
 }
 
+var daemonSetGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}
+
 func isOwnedByDaemonSet(pod *corev1.Pod) bool {
-	return isOwnedBy(pod, []schema.GroupVersionKind{
-		{Group: "apps", Version: "v1", Kind: "DaemonSet"},
-	})
+	return isOwnedBy(pod, []schema.GroupVersionKind{daemonSetGVK})
 }
 
 func isOwnedBy(pod *corev1.Pod, gvks []schema.GroupVersionKind) bool {
@@ -147,6 +151,41 @@ func sortDescendingFn() func(*corev1.Pod, *corev1.Pod) int {
 	}
 }
 
+// GetMaxRequestsPerNode sums up the CPU and memory requests of the scheduled pods per node and returns the maximum of
+// these sums across all nodes.
+func GetMaxRequestsPerNode(pods []corev1.Pod) corev1.ResourceList {
+	podsByNode := lo.GroupBy(lo.Filter(pods, func(pod corev1.Pod, _ int) bool {
+		return isScheduled(&pod)
+	}), func(pod corev1.Pod) string {
+		return pod.Spec.NodeName
+	})
+	maxResourceList := corev1.ResourceList{}
+	for _, nodePods := range podsByNode {
+		for name, q := range sumCPUAndMemoryRequests(nodePods) {
+			val, ok := maxResourceList[name]
+			if !ok || val.Cmp(q) < 0 {
+				maxResourceList[name] = q
+			}
+		}
+	}
+	return maxResourceList
+}
+
+func sumCPUAndMemoryRequests(pods []corev1.Pod) corev1.ResourceList {
+	var totalMemory resource.Quantity
+	var totalCPU resource.Quantity
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			totalMemory.Add(NilOr(container.Resources.Requests.Memory(), resource.Quantity{}))
+			totalCPU.Add(NilOr(container.Resources.Requests.Cpu(), resource.Quantity{}))
+		}
+	}
+	return corev1.ResourceList{
+		corev1.ResourceMemory: totalMemory,
+		corev1.ResourceCPU:    totalCPU,
+	}
+}
+
 func SortPodInfoByCreationTimestamp(a, b corev1.Pod) int {
 	return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
 }
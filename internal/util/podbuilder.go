@@ -60,6 +60,11 @@ func (p *PodBuilder) NominatedNodeName(nominatedNodeName string) *PodBuilder {
 	return p
 }
 
+func (p *PodBuilder) OwnerReferences(ownerReferences []metav1.OwnerReference) *PodBuilder {
+	p.objectMeta.OwnerReferences = ownerReferences
+	return p
+}
+
 func (p *PodBuilder) Count(count int) *PodBuilder {
 	p.count = count
 	return p
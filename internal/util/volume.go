@@ -0,0 +1,129 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	storagehelpers "k8s.io/component-helpers/storage/volume"
+	csitrans "k8s.io/csi-translation-lib"
+	volumeutil "k8s.io/kubernetes/pkg/volume/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"unmarshall/scaling-recommender/api"
+)
+
+// GetCSIDriverNames returns the sorted names of the CSI drivers which attach the persistent volumes and provision the
+// volumes of the storage classes.
+func GetCSIDriverNames(volumes api.Volumes) []string {
+	translator := csitrans.New()
+	drivers := sets.New[string]()
+	for _, pv := range volumes.PersistentVolumes {
+		if pv.Spec.CSI != nil {
+			drivers.Insert(pv.Spec.CSI.Driver)
+			continue
+		}
+		if !translator.IsPVMigratable(&pv) {
+			continue
+		}
+		if pluginName, err := translator.GetInTreePluginNameFromSpec(&pv, nil); err == nil {
+			drivers.Insert(GetCSIDriverName(pluginName))
+		}
+	}
+	for _, sc := range volumes.StorageClasses {
+		drivers.Insert(GetCSIDriverName(sc.Provisioner))
+	}
+	drivers.Delete("")
+	return sets.List(drivers)
+}
+
+// GetCSIDriverName returns the name of the CSI driver of a provisioner. In-tree volume plugins are translated to the CSI
+// driver their volumes are migrated to, the same way the kube-scheduler does. It returns an empty name if the provisioner
+// does not provision CSI volumes.
+func GetCSIDriverName(provisioner string) string {
+	translator := csitrans.New()
+	if translator.IsMigratableIntreePluginByName(provisioner) {
+		driver, err := translator.GetCSINameFromInTreeName(provisioner)
+		if err != nil {
+			return ""
+		}
+		return driver
+	}
+	// names of CSI drivers are DNS subdomains, the names of in-tree plugins like kubernetes.io/no-provisioner and of
+	// external provisioners contain a path.
+	if strings.Contains(provisioner, "/") {
+		return ""
+	}
+	return provisioner
+}
+
+// WithVolumeLimits returns a copy of allocatable which allows to attach at most limit volumes of each of the CSI drivers.
+func WithVolumeLimits(allocatable corev1.ResourceList, drivers []string, limit int) corev1.ResourceList {
+	revisedAllocatable := allocatable.DeepCopy()
+	if revisedAllocatable == nil {
+		revisedAllocatable = make(corev1.ResourceList, len(drivers))
+	}
+	for _, driver := range drivers {
+		revisedAllocatable[corev1.ResourceName(volumeutil.GetCSIAttachLimitKey(driver))] = *resource.NewQuantity(int64(limit), resource.DecimalSI)
+	}
+	return revisedAllocatable
+}
+
+// FindStorageClass returns the storage class of the claim or nil if the claim has no storage class or it is not found.
+func FindStorageClass(storageClasses []storagev1.StorageClass, claim *corev1.PersistentVolumeClaim) *storagev1.StorageClass {
+	className := storagehelpers.GetPersistentVolumeClaimClass(claim)
+	if className == "" {
+		return nil
+	}
+	idx := slices.IndexFunc(storageClasses, func(sc storagev1.StorageClass) bool {
+		return sc.Name == className
+	})
+	if idx < 0 {
+		return nil
+	}
+	return &storageClasses[idx]
+}
+
+// DeleteAllVolumes deletes the persistent volume claims in namespace, all persistent volumes and storage classes. The
+// protection finalizers of claims and volumes are removed first as no controller of the virtual cluster removes them.
+func DeleteAllVolumes(ctx context.Context, cl client.Client, namespace string) error {
+	var pvcList corev1.PersistentVolumeClaimList
+	if err := cl.List(ctx, &pvcList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list persistent volume claims: %w", err)
+	}
+	var pvList corev1.PersistentVolumeList
+	if err := cl.List(ctx, &pvList); err != nil {
+		return fmt.Errorf("failed to list persistent volumes: %w", err)
+	}
+	objects := append(lo.Map(pvcList.Items, func(pvc corev1.PersistentVolumeClaim, _ int) client.Object {
+		return &pvc
+	}), lo.Map(pvList.Items, func(pv corev1.PersistentVolume, _ int) client.Object {
+		return &pv
+	})...)
+	for _, obj := range objects {
+		if err := deleteWithoutFinalizers(ctx, cl, obj); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", client.ObjectKeyFromObject(obj), err)
+		}
+	}
+	if err := cl.DeleteAllOf(ctx, &storagev1.StorageClass{}); err != nil {
+		return fmt.Errorf("failed to delete all storage classes: %w", err)
+	}
+	return nil
+}
+
+func deleteWithoutFinalizers(ctx context.Context, cl client.Client, obj client.Object) error {
+	if len(obj.GetFinalizers()) > 0 {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		obj.SetFinalizers(nil)
+		if err := cl.Patch(ctx, obj, patch); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+	}
+	return client.IgnoreNotFound(cl.Delete(ctx, obj))
+}
@@ -7,14 +7,21 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 	"unmarshall/scaling-recommender/api"
 	"unmarshall/scaling-recommender/internal/scaler"
+	"unmarshall/scaling-recommender/internal/scaler/simulator"
 	"unmarshall/scaling-recommender/internal/simulation"
+	"unmarshall/scaling-recommender/internal/util"
 
 	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
 	seedmanagementv1alpha1 "github.com/gardener/gardener/pkg/apis/seedmanagement/v1alpha1"
 	machinev1alpha1 "github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"unmarshall/scaling-recommender/internal/app"
@@ -77,29 +84,129 @@ func parseCmdArgs() (api.AppConfig, error) {
 
 	fs.StringVar(&config.BinaryAssetsPath, "binary-assets-path", "", "path to the binary assets (kube-apiserver, etcd)")
 	fs.StringVar(&config.Provider, "provider", "", "provider of the target shoot")
-	fs.StringVar(&config.TargetKVCLKubeConfigPath, "target-kvcl-kubeconfig", "", "path to the kubeconfig of the target cluster")
+	fs.StringVar(&config.TargetKVCLKubeConfigPath, "target-kvcl-kubeconfig", "", "path to the kubeconfig of the target cluster, optional if pods are read from the snapshot")
+	fs.StringVar((*string)(&config.PodSource), "pod-source", "", "default source of pods: 'target' or 'snapshot', defaults to 'target' if target-kvcl-kubeconfig is set and 'snapshot' otherwise")
 	fs.StringVar(&config.ScoringStrategy, "scoring-strategy", string(scaler.CostOnlyStrategy), "scoring strategy")
+	fs.DurationVar(&config.JobRetention, "job-retention", time.Hour, "duration for which finished recommendation jobs are retained")
+	fs.StringVar(&config.ListenAddress, "listen-address", ":8080", "address on which the http server listens")
+	fs.StringVar(&config.TLSCertFile, "tls-cert-file", "", "path to the serving certificate, enables https when set together with tls-key-file")
+	fs.StringVar(&config.TLSKeyFile, "tls-key-file", "", "path to the private key of the serving certificate")
+	fs.StringVar(&config.TLSClientCAFile, "tls-client-ca-file", "", "path to the CA bundle used to verify client certificates (mTLS)")
+	fs.StringVar(&config.AuthTokenFile, "auth-token-file", "", "path to a file with the accepted bearer tokens, one per line")
+	fs.StringVar(&config.ArchiveDir, "archive-dir", filepath.Join(os.TempDir(), "scaling-recommender", "runs"), "directory in which the inputs and results of recommender runs are archived")
+	fs.IntVar(&config.ResponseCacheSize, "response-cache-size", 128, "maximum number of cached recommendation responses, 0 disables the cache")
+	fs.DurationVar(&config.ResponseCacheTTL, "response-cache-ttl", 15*time.Minute, "duration for which recommendation responses are cached")
+	fs.StringVar(&config.SimulatorBackend, "simulator-backend", string(simulator.KVCLBackend), "backend used to simulate the scheduling of pods: 'kvcl' or 'in-process'")
+	fs.IntVar(&config.SimulationWorkers, "simulation-workers", 4, "maximum number of node pool and zone candidates which are evaluated concurrently")
+	fs.StringVar(&config.SchedulerConfigPath, "scheduler-config", "", "path to a KubeSchedulerConfiguration whose first profile schedules the simulated pods, requires the in-process simulator backend")
+	fs.StringVar(&config.AuthTokenReviewKubeConfigPath, "auth-token-review-kubeconfig", "", "path to the kubeconfig of the API server used to verify bearer tokens via TokenReview")
+	systemOverhead := fs.String("system-overhead", "", "resources reserved for system components on every node, e.g. cpu=500m,memory=1Gi. If not set, it is derived from the kube-system pods of cluster snapshots")
+	nodeReservation := fs.String("node-reservation", "", "resources reserved for the kubelet and the operating system on nodes of node templates synthesised from the pricing catalog, e.g. cpu=80m,memory=10%. CPU and memory which are not set follow the tiered kube-reserved formula of GKE")
 
 	if err := fs.Parse(args); err != nil {
 		return config, err
 	}
+	resolvePodSource(&config)
+	if err := resolveSystemOverhead(&config, *systemOverhead); err != nil {
+		return config, err
+	}
+	if err := resolveNodeReservation(&config, *nodeReservation); err != nil {
+		return config, err
+	}
 	err := resolveBinaryAssetsPath(&config)
 	return config, err
 }
 
 func validateConfig(config api.AppConfig) error {
-	if config.BinaryAssetsPath == "" {
-		return fmt.Errorf("binary assets path is required")
+	if !simulator.IsBackendSupported(config.SimulatorBackend) {
+		return fmt.Errorf("simulator backend %s is not supported", config.SimulatorBackend)
+	}
+	if config.SimulatorBackend == string(simulator.KVCLBackend) && config.BinaryAssetsPath == "" {
+		return fmt.Errorf("binary assets path is required by the %s simulator backend", simulator.KVCLBackend)
+	}
+	if config.SchedulerConfigPath != "" && !simulator.Backend(config.SimulatorBackend).SupportsSchedulerProfiles() {
+		return fmt.Errorf("scheduler-config is not supported by the %s simulator backend", config.SimulatorBackend)
+	}
+	if config.SimulationWorkers < 1 {
+		return fmt.Errorf("simulation workers must be at least 1")
 	}
 	if config.Provider == "" {
 		return fmt.Errorf("provider is required")
 	}
-	if config.TargetKVCLKubeConfigPath == "" {
-		return fmt.Errorf("kubeconfig path is required")
+	switch config.PodSource {
+	case api.PodSourceSnapshot:
+	case api.PodSourceTarget:
+		if config.TargetKVCLKubeConfigPath == "" {
+			return fmt.Errorf("kubeconfig path is required when pods are read from the target cluster")
+		}
+	default:
+		return fmt.Errorf("pod source %s is not supported", config.PodSource)
 	}
 	if !scaler.IsScoringStrategySupported(config.ScoringStrategy) {
 		return fmt.Errorf("scoring strategy %s is not supported", config.ScoringStrategy)
 	}
+	if config.JobRetention <= 0 {
+		return fmt.Errorf("job retention must be positive")
+	}
+	if config.ArchiveDir == "" {
+		return fmt.Errorf("archive directory is required")
+	}
+	if config.ResponseCacheSize < 0 {
+		return fmt.Errorf("response cache size must not be negative")
+	}
+	if config.ResponseCacheSize > 0 && config.ResponseCacheTTL <= 0 {
+		return fmt.Errorf("response cache ttl must be positive")
+	}
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return fmt.Errorf("both tls-cert-file and tls-key-file must be set to enable tls")
+	}
+	if config.TLSClientCAFile != "" && config.TLSCertFile == "" {
+		return fmt.Errorf("tls-client-ca-file requires tls-cert-file and tls-key-file")
+	}
+	return nil
+}
+
+func resolvePodSource(config *api.AppConfig) {
+	if config.PodSource != "" {
+		return
+	}
+	if config.TargetKVCLKubeConfigPath == "" {
+		config.PodSource = api.PodSourceSnapshot
+	} else {
+		config.PodSource = api.PodSourceTarget
+	}
+}
+
+// resolveSystemOverhead parses a comma separated list of resource name and quantity pairs.
+func resolveSystemOverhead(config *api.AppConfig, systemOverhead string) error {
+	if systemOverhead == "" {
+		return nil
+	}
+	config.SystemOverhead = make(corev1.ResourceList)
+	for _, pair := range strings.Split(systemOverhead, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("invalid system overhead %q, expected <resource>=<quantity>", pair)
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid system overhead for %s: %w", name, err)
+		}
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("system overhead for %s must not be negative", name)
+		}
+		config.SystemOverhead[corev1.ResourceName(name)] = quantity
+	}
+	return nil
+}
+
+// resolveNodeReservation parses a comma separated list of resource name and quantity or percentage pairs.
+func resolveNodeReservation(config *api.AppConfig, nodeReservation string) error {
+	reservation, err := util.ParseNodeReservation(nodeReservation)
+	if err != nil {
+		return err
+	}
+	config.NodeReservation = reservation
 	return nil
 }
 
@@ -107,7 +214,7 @@ func resolveBinaryAssetsPath(config *api.AppConfig) error {
 	if config.BinaryAssetsPath == "" {
 		config.BinaryAssetsPath = getBinaryAssetsPathFromEnv()
 	}
-	if config.BinaryAssetsPath == "" {
+	if config.BinaryAssetsPath == "" && config.SimulatorBackend == string(simulator.KVCLBackend) {
 		return fmt.Errorf("cannot find binary-assets-path")
 	}
 	return nil
@@ -0,0 +1,328 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Error is a problem found in a scenario. Decoding errors carry the Line and Column at which they occurred,
+// validation errors carry the Field path (e.g. pods[2].requests) of the offending value. Validation errors of a parsed
+// scenario also carry the Line and Column of that value, or of the closest enclosing value if the field is missing.
+type Error struct {
+	Line    int
+	Column  int
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	var location string
+	switch {
+	case e.Line > 0 && e.Field != "":
+		location = fmt.Sprintf("line %d, column %d: %s: ", e.Line, e.Column, e.Field)
+	case e.Line > 0:
+		location = fmt.Sprintf("line %d, column %d: ", e.Line, e.Column)
+	case e.Field != "":
+		location = e.Field + ": "
+	}
+	return location + e.Message
+}
+
+// ErrorList is the list of all problems found in a scenario.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l *ErrorList) add(field, format string, args ...any) {
+	*l = append(*l, &Error{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// locate sets the line and column of the errors to the position of their field in data.
+func (l ErrorList) locate(data []byte) {
+	for _, e := range l {
+		if offset, ok := locateField(data, e.Field); ok {
+			e.Line, e.Column = position(data, offset)
+		}
+	}
+}
+
+// newPositionError creates an Error for the given byte offset into data.
+func newPositionError(data []byte, offset int64, field, message string) *Error {
+	line, column := position(data, offset)
+	return &Error{Line: line, Column: column, Field: field, Message: message}
+}
+
+func position(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	prefix := data[:offset]
+	line = bytes.Count(prefix, []byte("\n")) + 1
+	column = int(offset) - (bytes.LastIndexByte(prefix, '\n') + 1) + 1
+	return
+}
+
+// jsonUnmarshalerType is the type of json.Unmarshaler, whose implementations decode their JSON themselves.
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// locateUnknownField finds the first object key in data which is not a field of the struct decoded from it when data is
+// decoded into t. It returns the offset of the key and its path, e.g. pods[2].cpuRequest.
+func locateUnknownField(data []byte, t reflect.Type) (offset int64, field string, ok bool) {
+	w := &unknownFieldWalker{dec: json.NewDecoder(bytes.NewReader(data)), data: data}
+	if err := w.walk(t, ""); err != nil {
+		return 0, "", false
+	}
+	return w.offset, w.field, w.found
+}
+
+// unknownFieldWalker walks the tokens of a JSON document along the Go type it is decoded into. A nil type accepts any
+// value, it is used for values which are decoded by an json.Unmarshaler or into an interface.
+type unknownFieldWalker struct {
+	dec    *json.Decoder
+	data   []byte
+	found  bool
+	offset int64
+	field  string
+}
+
+func (w *unknownFieldWalker) walk(t reflect.Type, path string) error {
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(jsonUnmarshalerType)) {
+		t = nil
+	}
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() && !w.found {
+			keyTok, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			var valueType reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				valueType = t.Elem()
+			case t.Kind() == reflect.Struct:
+				var known bool
+				if valueType, known = lookupJSONField(t, key); !known {
+					w.found = true
+					w.offset = int64(bytes.LastIndexByte(w.data[:w.dec.InputOffset()-1], '"'))
+					w.field = keyPath
+					return nil
+				}
+			}
+			if err = w.walk(valueType, keyPath); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for i := 0; w.dec.More() && !w.found; i++ {
+			if err = w.walk(elemType, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	if w.found {
+		return nil
+	}
+	// closing delimiter
+	_, err = w.dec.Token()
+	return err
+}
+
+// lookupJSONField returns the type of the field of struct t to which encoding/json decodes key, preferring an exact
+// match of the field name over a case-insensitive one. Fields of embedded structs without a name are promoted.
+func lookupJSONField(t reflect.Type, key string) (reflect.Type, bool) {
+	var foldMatch reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if fieldType, ok := lookupJSONField(embedded, key); ok {
+					return fieldType, true
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f.Type, true
+		}
+		if foldMatch == nil && strings.EqualFold(name, key) {
+			foldMatch = f.Type
+		}
+	}
+	return foldMatch, foldMatch != nil
+}
+
+// locateField returns the offset of the value at field, a path like pods[2].requests, in data. If the field is missing,
+// the offset of the closest enclosing value is returned. Keys are matched case-insensitively like encoding/json does.
+func locateField(data []byte, field string) (int64, bool) {
+	if field == "" {
+		return 0, false
+	}
+	w := &fieldLocator{dec: json.NewDecoder(bytes.NewReader(data)), data: data}
+	offset, err := w.locate(splitFieldPath(field))
+	if err != nil {
+		return 0, false
+	}
+	return offset, true
+}
+
+// fieldPath converts a field reported by encoding/json, e.g. pods.2.count, into a field path like pods[2].count.
+func fieldPath(jsonField string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(jsonField, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// fieldPathSegment is an object key or, if key is empty, an array index of a field path.
+type fieldPathSegment struct {
+	key   string
+	index int
+}
+
+func splitFieldPath(field string) []fieldPathSegment {
+	var segments []fieldPathSegment
+	for _, part := range strings.Split(field, ".") {
+		key, indices, _ := strings.Cut(part, "[")
+		if key != "" {
+			segments = append(segments, fieldPathSegment{key: key})
+		}
+		for indices != "" {
+			var index string
+			index, indices, _ = strings.Cut(indices, "]")
+			indices = strings.TrimPrefix(indices, "[")
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				return segments
+			}
+			segments = append(segments, fieldPathSegment{index: i})
+		}
+	}
+	return segments
+}
+
+// fieldLocator walks the tokens of a JSON document along a field path.
+type fieldLocator struct {
+	dec  *json.Decoder
+	data []byte
+}
+
+// locate returns the offset of the value at path which starts at the next token.
+func (w *fieldLocator) locate(path []fieldPathSegment) (int64, error) {
+	offset := w.nextValueOffset()
+	if len(path) == 0 {
+		return offset, nil
+	}
+	tok, err := w.dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case tok == json.Delim('{') && path[0].key != "":
+		for w.dec.More() {
+			keyTok, err := w.dec.Token()
+			if err != nil {
+				return 0, err
+			}
+			if strings.EqualFold(keyTok.(string), path[0].key) {
+				return w.locate(path[1:])
+			}
+			if err = w.skipValue(); err != nil {
+				return 0, err
+			}
+		}
+	case tok == json.Delim('[') && path[0].key == "":
+		for i := 0; w.dec.More(); i++ {
+			if i == path[0].index {
+				return w.locate(path[1:])
+			}
+			if err = w.skipValue(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return offset, nil
+}
+
+// nextValueOffset returns the offset of the next value, skipping the whitespace and separators before it.
+func (w *fieldLocator) nextValueOffset() int64 {
+	offset := w.dec.InputOffset()
+	for offset < int64(len(w.data)) && strings.IndexByte(" \t\r\n:,", w.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func (w *fieldLocator) skipValue() error {
+	depth := 0
+	for {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	gsc "github.com/elankath/gardener-scaling-common"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"unmarshall/scaling-recommender/api"
+	"unmarshall/scaling-recommender/internal/common"
+)
+
+const (
+	podContainerName  = "pause"
+	podContainerImage = "registry.k8s.io/pause:3.5"
+)
+
+// nodeSpecificLabels are the labels of an existing node which are not carried over to a derived node template.
+var nodeSpecificLabels = []string{
+	corev1.LabelHostname,
+	corev1.LabelTopologyZone,
+	corev1.LabelFailureDomainBetaZone,
+	common.WorkerPoolLabelKey,
+}
+
+// ParseFile reads and validates the scenario in the file at path.
+func ParseFile(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a scenario. Unknown fields are rejected. The returned error is an ErrorList
+// if the scenario could be decoded but is not valid, and an *Error if it could not be decoded. Both carry the line
+// and column of the problem in data.
+func Parse(data []byte) (*Scenario, error) {
+	s, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if s.Version == "" {
+		s.Version = CurrentVersion
+	}
+	if err = s.Validate(); err != nil {
+		var errs ErrorList
+		if errors.As(err, &errs) {
+			errs.locate(data)
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSimulationRequest parses the scenario in the file at path and expands it into an api.SimulationRequest.
+func LoadSimulationRequest(path string) (*api.SimulationRequest, error) {
+	s, err := ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario %s:\n%w", path, err)
+	}
+	return s.ToSimulationRequest()
+}
+
+func decode(data []byte) (*Scenario, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	s := &Scenario{}
+	if err := dec.Decode(s); err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxError):
+			return nil, newPositionError(data, syntaxError.Offset, "", "badly-formed JSON: "+syntaxError.Error())
+		case errors.As(err, &unmarshalTypeError):
+			// the decoder reports the offset after the value and the field with dotted indices, e.g. pods.2.count.
+			field := fieldPath(unmarshalTypeError.Field)
+			offset, ok := locateField(data, field)
+			if !ok {
+				offset = unmarshalTypeError.Offset
+			}
+			return nil, newPositionError(data, offset, field,
+				fmt.Sprintf("cannot use JSON %s as %s", unmarshalTypeError.Value, unmarshalTypeError.Type))
+		case errors.Is(err, io.EOF):
+			return nil, &Error{Message: "scenario must not be empty"}
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, newPositionError(data, int64(len(data)), "", "unexpected end of JSON")
+		default:
+			message := strings.TrimPrefix(err.Error(), "json: ")
+			// the decoder reports unknown fields at the end of the document, so the offending key is located separately.
+			if strings.HasPrefix(message, "unknown field ") {
+				if offset, field, ok := locateUnknownField(data, reflect.TypeOf(s)); ok {
+					return nil, newPositionError(data, offset, field, message)
+				}
+			}
+			return nil, newPositionError(data, dec.InputOffset(), "", message)
+		}
+	}
+	if dec.More() {
+		return nil, newPositionError(data, dec.InputOffset(), "", "unexpected data after the scenario")
+	}
+	return s, nil
+}
+
+// Validate checks that the scenario is complete and consistent.
+func (s *Scenario) Validate() error {
+	var errs ErrorList
+	if s.Version != "" && s.Version != VersionV1 {
+		errs.add("version", "unsupported version %q, supported versions are [%s]", s.Version, VersionV1)
+	}
+	if len(s.NodePools) == 0 {
+		errs.add("nodePools", "at least one node pool is required")
+	}
+	pools := make(map[string]NodePool, len(s.NodePools))
+	for i, np := range s.NodePools {
+		field := fmt.Sprintf("nodePools[%d]", i)
+		if np.Name == "" {
+			errs.add(field+".name", "must not be empty")
+		} else if _, ok := pools[np.Name]; ok {
+			errs.add(field+".name", "duplicate node pool %q", np.Name)
+		}
+		pools[np.Name] = np
+		if len(np.Zones) == 0 {
+			errs.add(field+".zones", "at least one zone is required")
+		}
+		if np.InstanceType == "" {
+			errs.add(field+".instanceType", "must not be empty")
+		}
+		if np.Current < 0 || np.Max < np.Current {
+			errs.add(field, "current (%d) must be between 0 and max (%d)", np.Current, np.Max)
+		}
+	}
+
+	nodes := make(map[string]Node, len(s.Nodes))
+	for i, n := range s.Nodes {
+		field := fmt.Sprintf("nodes[%d]", i)
+		if n.Name == "" {
+			errs.add(field+".name", "must not be empty")
+		} else if _, ok := nodes[n.Name]; ok {
+			errs.add(field+".name", "duplicate node %q", n.Name)
+		}
+		nodes[n.Name] = n
+		if n.Labels[corev1.LabelInstanceTypeStable] == "" {
+			errs.add(field+".labels", "label %s is required", corev1.LabelInstanceTypeStable)
+		}
+		if len(n.Allocatable) == 0 {
+			errs.add(field+".allocatable", "must not be empty")
+		}
+	}
+
+	claims := sets.New[string]()
+	for _, pvc := range s.PersistentVolumeClaims {
+		claims.Insert(pvc.Name)
+	}
+	priorityClasses := sets.New[string]()
+	for _, pc := range s.PriorityClasses {
+		priorityClasses.Insert(pc.Name)
+	}
+	if len(s.Pods) == 0 {
+		errs.add("pods", "at least one pod is required")
+	}
+	for i, p := range s.Pods {
+		field := fmt.Sprintf("pods[%d]", i)
+		if strings.TrimSuffix(p.NamePrefix, "-") == "" {
+			errs.add(field+".namePrefix", "must not be empty")
+		}
+		if len(p.Requests) == 0 {
+			errs.add(field+".requests", "at least one resource request is required")
+		}
+		if p.Count < 0 {
+			errs.add(field+".count", "must not be negative")
+		}
+		if p.PriorityClassName != "" && !strings.HasPrefix(p.PriorityClassName, "system-") && !priorityClasses.Has(p.PriorityClassName) {
+			errs.add(field+".priorityClassName", "priority class %q is not defined in priorityClasses", p.PriorityClassName)
+		}
+		for j, claimName := range p.PersistentVolumeClaims {
+			if !claims.Has(claimName) {
+				errs.add(fmt.Sprintf("%s.persistentVolumeClaims[%d]", field, j), "persistent volume claim %q is not defined in persistentVolumeClaims", claimName)
+			}
+		}
+		if p.ScheduledOn != nil {
+			validateNodeReference(&errs, field+".scheduledOn", *p.ScheduledOn, nodes, pools)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateNodeReference(errs *ErrorList, field string, ref api.NodeReference, nodes map[string]Node, pools map[string]NodePool) {
+	if _, ok := nodes[ref.Name]; !ok {
+		errs.add(field+".name", "node %q is not defined in nodes", ref.Name)
+	}
+	if ref.PoolName == "" {
+		return
+	}
+	np, ok := pools[ref.PoolName]
+	if !ok {
+		errs.add(field+".poolName", "node pool %q is not defined in nodePools", ref.PoolName)
+		return
+	}
+	if ref.Zone != "" && !sets.New(np.Zones...).Has(ref.Zone) {
+		errs.add(field+".zone", "zone %q is not a zone of node pool %q", ref.Zone, ref.PoolName)
+	}
+}
+
+// ToSimulationRequest expands the scenario into an api.SimulationRequest. The scenario is expected to be valid.
+func (s *Scenario) ToSimulationRequest() (*api.SimulationRequest, error) {
+	simRequest := &api.SimulationRequest{
+		ID:               s.ID,
+		NodePools:        make([]api.NodePool, 0, len(s.NodePools)),
+		Pods:             make([]api.PodInfo, 0, len(s.Pods)),
+		PriorityClasses:  s.PriorityClasses,
+		Nodes:            s.toNodeInfos(),
+		NodeTemplates:    s.nodeTemplates(),
+		PodOrder:         s.PodOrder,
+		Seed:             s.Seed,
+		SchedulerProfile: s.SchedulerProfile,
+		SystemOverhead:   s.SystemOverhead,
+		Volumes:          s.Volumes,
+	}
+	for _, np := range s.NodePools {
+		simRequest.NodePools = append(simRequest.NodePools, api.NodePool{
+			Name:         np.Name,
+			Zones:        sets.New(np.Zones...),
+			Max:          np.Max,
+			Current:      np.Current,
+			InstanceType: np.InstanceType,
+		})
+	}
+	for _, p := range s.Pods {
+		simRequest.Pods = append(simRequest.Pods, p.toPodInfo())
+	}
+	return simRequest, nil
+}
+
+func (p Pod) toPodInfo() api.PodInfo {
+	count := p.Count
+	if count == 0 {
+		count = 1
+	}
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  podContainerName,
+				Image: podContainerImage,
+				Resources: corev1.ResourceRequirements{
+					Requests: p.Requests.DeepCopy(),
+				},
+			},
+		},
+		NodeSelector:              p.NodeSelector,
+		Affinity:                  p.Affinity,
+		Tolerations:               p.Tolerations,
+		PriorityClassName:         p.PriorityClassName,
+		TopologySpreadConstraints: p.TopologySpreadConstraints,
+	}
+	for _, claimName := range p.PersistentVolumeClaims {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         claimName,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}},
+		})
+	}
+	if p.ScheduledOn != nil {
+		spec.NodeName = p.ScheduledOn.Name
+	}
+	podInfo := api.PodInfo{
+		Name:   strings.TrimSuffix(p.NamePrefix, "-"),
+		Labels: p.Labels,
+		Spec:   spec,
+		Count:  count,
+	}
+	if p.DaemonSet {
+		podInfo.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "DaemonSet", Name: podInfo.Name},
+		}
+	}
+	return podInfo
+}
+
+// toNodeInfos converts the existing nodes. Pool and zone labels missing on a node are taken from the scheduledOn
+// references of the pods running on it.
+func (s *Scenario) toNodeInfos() []api.NodeInfo {
+	refs := make(map[string]api.NodeReference)
+	for _, p := range s.Pods {
+		if p.ScheduledOn != nil {
+			refs[p.ScheduledOn.Name] = *p.ScheduledOn
+		}
+	}
+	nodeInfos := make([]api.NodeInfo, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		labels := make(map[string]string, len(n.Labels)+3)
+		for k, v := range n.Labels {
+			labels[k] = v
+		}
+		setIfAbsent(labels, corev1.LabelHostname, n.Name)
+		if ref, ok := refs[n.Name]; ok {
+			setIfAbsent(labels, common.WorkerPoolLabelKey, ref.PoolName)
+			setIfAbsent(labels, corev1.LabelTopologyZone, ref.Zone)
+		}
+		nodeInfos = append(nodeInfos, api.NodeInfo{
+			Name:        n.Name,
+			Labels:      labels,
+			Taints:      n.Taints,
+			Allocatable: n.Allocatable,
+			Capacity:    n.Capacity,
+		})
+	}
+	return nodeInfos
+}
+
+// nodeTemplates returns a template per instance type used by the scenario. Templates which are not part of the
+// scenario are derived from an existing node of the same instance type. Instance types for which neither is
+// available are left out and have to be resolved by the recommender.
+func (s *Scenario) nodeTemplates() map[string]gsc.NodeTemplate {
+	nodeTemplates := make(map[string]gsc.NodeTemplate)
+	for instanceType := range s.instanceTypes() {
+		if nt, ok := s.NodeTemplates[instanceType]; ok {
+			if nt.InstanceType == "" {
+				nt.InstanceType = instanceType
+			}
+			nodeTemplates[instanceType] = nt
+			continue
+		}
+		node := s.findNode(instanceType)
+		if node == nil {
+			continue
+		}
+		labels := make(map[string]string, len(node.Labels))
+		for k, v := range node.Labels {
+			labels[k] = v
+		}
+		for _, l := range nodeSpecificLabels {
+			delete(labels, l)
+		}
+		nodeTemplates[instanceType] = gsc.NodeTemplate{
+			Name:         instanceType,
+			InstanceType: instanceType,
+			Region:       node.Labels[corev1.LabelTopologyRegion],
+			Capacity:     node.Capacity,
+			Allocatable:  node.Allocatable,
+			Labels:       labels,
+		}
+	}
+	return nodeTemplates
+}
+
+func (s *Scenario) instanceTypes() sets.Set[string] {
+	instanceTypes := sets.New[string]()
+	for _, np := range s.NodePools {
+		if np.InstanceType != "" {
+			instanceTypes.Insert(np.InstanceType)
+		}
+	}
+	for _, n := range s.Nodes {
+		if it := n.Labels[corev1.LabelInstanceTypeStable]; it != "" {
+			instanceTypes.Insert(it)
+		}
+	}
+	return instanceTypes
+}
+
+func (s *Scenario) findNode(instanceType string) *Node {
+	for i := range s.Nodes {
+		if s.Nodes[i].Labels[corev1.LabelInstanceTypeStable] == instanceType {
+			return &s.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func setIfAbsent(m map[string]string, key, value string) {
+	if _, ok := m[key]; !ok && value != "" {
+		m[key] = value
+	}
+}
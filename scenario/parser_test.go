@@ -0,0 +1,226 @@
+package scenario
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validScenario is the smallest valid scenario.
+const validScenario = `{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": 3, "instanceType": "m5.large"}
+  ],
+  "pods": [
+    {"namePrefix": "web-", "requests": {"cpu": "100m"}}
+  ]
+}`
+
+func TestParseRejectsUndecodableScenarios(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantLine    int
+		wantColumn  int
+		wantField   string
+		wantMessage string
+	}{
+		{
+			name: "unknown key",
+			data: `{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": 3, "instanceType": "m5.large"}
+  ],
+  "pods": [
+    {"namePrefix": "web-", "cpuRequest": "100m"}
+  ]
+}`,
+			wantLine:    6,
+			wantColumn:  28,
+			wantField:   "pods[0].cpuRequest",
+			wantMessage: `unknown field "cpuRequest"`,
+		},
+		{
+			name: "wrong type",
+			data: `{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": "3", "instanceType": "m5.large"}
+  ]
+}`,
+			wantLine:    3,
+			wantColumn:  44,
+			wantField:   "nodePools[0].max",
+			wantMessage: "cannot use JSON string as int32",
+		},
+		{
+			name: "truncated JSON",
+			data: `{
+  "nodePools": [
+    {"name": "p1"`,
+			wantLine:    3,
+			wantColumn:  18,
+			wantMessage: "unexpected end of JSON",
+		},
+		{
+			name:        "empty",
+			data:        "",
+			wantMessage: "scenario must not be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if e.Line != tt.wantLine || e.Column != tt.wantColumn || e.Field != tt.wantField || e.Message != tt.wantMessage {
+				t.Errorf("got %+v, want line %d, column %d, field %q and message %q", *e, tt.wantLine, tt.wantColumn, tt.wantField, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestParseReportsInvalidReferencesAtTheirValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		errors []Error
+	}{
+		{
+			name: "undefined node, node pool and zone",
+			data: `{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": 3, "instanceType": "m5.large"}
+  ],
+  "nodes": [
+    {"name": "n1", "labels": {"node.kubernetes.io/instance-type": "m5.large"}, "allocatable": {"cpu": "2"}}
+  ],
+  "pods": [
+    {"namePrefix": "a-", "requests": {"cpu": "100m"}, "scheduledOn": {"name": "n2"}},
+    {"namePrefix": "b-", "requests": {"cpu": "100m"}, "scheduledOn": {"name": "n1", "poolName": "p2"}},
+    {"namePrefix": "c-", "requests": {"cpu": "100m"}, "scheduledOn": {"name": "n1", "poolName": "p1", "zone": "z2"}}
+  ]
+}`,
+			errors: []Error{
+				{Line: 9, Column: 79, Field: "pods[0].scheduledOn.name", Message: `node "n2" is not defined in nodes`},
+				{Line: 10, Column: 97, Field: "pods[1].scheduledOn.poolName", Message: `node pool "p2" is not defined in nodePools`},
+				{Line: 11, Column: 111, Field: "pods[2].scheduledOn.zone", Message: `zone "z2" is not a zone of node pool "p1"`},
+			},
+		},
+		{
+			name: "undefined priority class and persistent volume claim",
+			data: `{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": 3, "instanceType": "m5.large"}
+  ],
+  "pods": [
+    {
+      "namePrefix": "a-",
+      "requests": {"cpu": "100m"},
+      "priorityClassName": "high",
+      "persistentVolumeClaims": ["data"]
+    }
+  ]
+}`,
+			errors: []Error{
+				{Line: 9, Column: 28, Field: "pods[0].priorityClassName", Message: `priority class "high" is not defined in priorityClasses`},
+				{Line: 10, Column: 34, Field: "pods[0].persistentVolumeClaims[0]", Message: `persistent volume claim "data" is not defined in persistentVolumeClaims`},
+			},
+		},
+		{
+			name: "missing field is reported at the enclosing object",
+			data: `{
+  "nodePools": [
+    {"name": "p1", "max": 3, "instanceType": "m5.large"}
+  ],
+  "pods": [
+    {"namePrefix": "a-", "requests": {"cpu": "100m"}}
+  ]
+}`,
+			errors: []Error{
+				{Line: 3, Column: 5, Field: "nodePools[0].zones", Message: "at least one zone is required"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			var errs ErrorList
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected an ErrorList, got %v", err)
+			}
+			if len(errs) != len(tt.errors) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(tt.errors), errs)
+			}
+			for i, e := range errs {
+				if *e != tt.errors[i] {
+					t.Errorf("error %d: got %+v, want %+v", i, *e, tt.errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToSimulationRequestExpandsPods(t *testing.T) {
+	s, err := Parse([]byte(`{
+  "nodePools": [
+    {"name": "p1", "zones": ["z1"], "max": 3, "instanceType": "m5.large"}
+  ],
+  "persistentVolumeClaims": [
+    {"metadata": {"name": "data"}}
+  ],
+  "pods": [
+    {"namePrefix": "web-", "requests": {"cpu": "100m"}, "count": 3},
+    {"namePrefix": "single-", "requests": {"cpu": "100m"}},
+    {"namePrefix": "agent-", "requests": {"cpu": "50m"}, "daemonSet": true},
+    {"namePrefix": "db-", "requests": {"cpu": "1"}, "persistentVolumeClaims": ["data"]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	simRequest, err := s.ToSimulationRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(simRequest.Pods) != 4 {
+		t.Fatalf("got %d pods, want 4", len(simRequest.Pods))
+	}
+	web, single, agent, db := simRequest.Pods[0], simRequest.Pods[1], simRequest.Pods[2], simRequest.Pods[3]
+
+	if web.Name != "web" || web.Count != 3 {
+		t.Errorf("got pod %q with count %d, want pod web with count 3", web.Name, web.Count)
+	}
+	if single.Count != 1 {
+		t.Errorf("got count %d for a pod without count, want 1", single.Count)
+	}
+	if len(web.OwnerReferences) != 0 {
+		t.Errorf("got owner references %v for a pod which is not part of a DaemonSet", web.OwnerReferences)
+	}
+	if len(agent.OwnerReferences) != 1 || agent.OwnerReferences[0].Kind != "DaemonSet" || agent.OwnerReferences[0].Name != "agent" {
+		t.Errorf("got owner references %v, want the DaemonSet agent", agent.OwnerReferences)
+	}
+	wantVolumes := []corev1.Volume{{
+		Name:         "data",
+		VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+	}}
+	if len(db.Spec.Volumes) != 1 || db.Spec.Volumes[0].Name != wantVolumes[0].Name ||
+		db.Spec.Volumes[0].PersistentVolumeClaim == nil || db.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != "data" {
+		t.Errorf("got volumes %v, want %v", db.Spec.Volumes, wantVolumes)
+	}
+	if len(simRequest.PersistentVolumeClaims) != 1 || simRequest.PersistentVolumeClaims[0].Name != "data" {
+		t.Errorf("got persistent volume claims %v, want data", simRequest.PersistentVolumeClaims)
+	}
+}
+
+func TestParseAcceptsValidScenario(t *testing.T) {
+	s, err := Parse([]byte(validScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != CurrentVersion {
+		t.Errorf("got version %q, want %q", s.Version, CurrentVersion)
+	}
+}
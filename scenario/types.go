@@ -0,0 +1,80 @@
+package scenario
+
+import (
+	gsc "github.com/elankath/gardener-scaling-common"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	schedulerv1 "k8s.io/kube-scheduler/config/v1"
+
+	"unmarshall/scaling-recommender/api"
+)
+
+const (
+	// VersionV1 is the first and currently only version of the scenario format.
+	VersionV1 = "v1"
+	// CurrentVersion is the version assumed for scenarios that do not declare one.
+	CurrentVersion = VersionV1
+)
+
+// Scenario is a hand-written what-if scenario. Pods are described in a compact form which is expanded into full
+// pod specs when the scenario is converted into an api.SimulationRequest.
+type Scenario struct {
+	// Version is the version of the scenario format. If empty, CurrentVersion is assumed.
+	Version   string     `json:"version,omitempty"`
+	ID        string     `json:"id"`
+	NodePools []NodePool `json:"nodePools"`
+	Pods      []Pod      `json:"pods"`
+	Nodes     []Node     `json:"nodes,omitempty"`
+	// NodeTemplates are keyed on the instance type. Templates which are not listed are derived from an existing
+	// node of the same instance type.
+	NodeTemplates   map[string]gsc.NodeTemplate  `json:"nodeTemplates,omitempty"`
+	PriorityClasses []schedulingv1.PriorityClass `json:"priorityClasses,omitempty"`
+	// PodOrder is the order in which pods will be sorted and scheduled.
+	PodOrder *string `json:"podOrder,omitempty"`
+	// Seed makes the recommendation for the scenario reproducible.
+	Seed *int64 `json:"seed,omitempty"`
+	// SchedulerProfile is the profile with which the pods of the scenario are scheduled.
+	SchedulerProfile *schedulerv1.KubeSchedulerProfile `json:"schedulerProfile,omitempty"`
+	// SystemOverhead are the resources reserved for system components on every node.
+	SystemOverhead corev1.ResourceList `json:"systemOverhead,omitempty"`
+	// Volumes are the storage objects referenced by the persistent volume claims of the pods.
+	api.Volumes
+}
+
+// NodePool is a worker pool which can be scaled up.
+type NodePool struct {
+	Name         string   `json:"name"`
+	Zones        []string `json:"zones"`
+	Max          int32    `json:"max"`
+	Current      int32    `json:"current"`
+	InstanceType string   `json:"instanceType"`
+}
+
+// Pod describes Count identical pods. Names of the pods are generated from NamePrefix.
+type Pod struct {
+	NamePrefix                string                            `json:"namePrefix"`
+	Labels                    map[string]string                 `json:"labels,omitempty"`
+	Requests                  corev1.ResourceList               `json:"requests"`
+	Count                     int                               `json:"count,omitempty"`
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	NodeSelector              map[string]string                 `json:"nodeSelector,omitempty"`
+	Affinity                  *corev1.Affinity                  `json:"affinity,omitempty"`
+	Tolerations               []corev1.Toleration               `json:"tolerations,omitempty"`
+	PriorityClassName         string                            `json:"priorityClassName,omitempty"`
+	// ScheduledOn is the existing node on which the pods are already running. If nil, the pods are pending.
+	ScheduledOn *api.NodeReference `json:"scheduledOn,omitempty"`
+	// DaemonSet marks the pods as pods of a DaemonSet named after the NamePrefix. Such pods are placed on every new node
+	// they tolerate, pending ones are never scheduled.
+	DaemonSet bool `json:"daemonSet,omitempty"`
+	// PersistentVolumeClaims are the names of the claims mounted by each of the pods.
+	PersistentVolumeClaims []string `json:"persistentVolumeClaims,omitempty"`
+}
+
+// Node is an existing node of the cluster.
+type Node struct {
+	Name        string              `json:"name"`
+	Labels      map[string]string   `json:"labels,omitempty"`
+	Taints      []corev1.Taint      `json:"taints,omitempty"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+	Capacity    corev1.ResourceList `json:"capacity"`
+}